@@ -4,10 +4,17 @@ package apitpl
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"github.com/pkg/errors"
 	"html/template"
 	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template/parse"
+	"time"
 
 	"github.com/oxtoacart/bpool"
 
@@ -19,24 +26,110 @@ import (
 // TemplateService holds templates data & methods
 type TemplateService struct {
 	lfs              *lookupfs.LookupFileSystem
+	defLayout        string
 	funcMap          template.FuncMap
-	layouts          map[string]*template.Template
-	pages            map[string]*template.Template
-	baseTemplate     *template.Template
+	mu               sync.Mutex // serializes lookups & parsing
+	state            atomic.Pointer[snapshot]
 	bufPool          *bpool.BufferPool
+	sections         sectionStore
 	useCustomContent bool
 	parseAlways      bool
+	stream           bool
+	watchInterval    time.Duration
+	watchErrors      func(error)
+	watchStop        chan struct{}
+	watchDone        chan struct{}
+	protoFuncs       template.FuncMap
+	providers        []FuncProvider
+	strictFuncs      bool
+	limits           limits
+	engine           Engine
+	treeEngines      map[string]Engine
 }
 
 // codebeat:enable[TOO_MANY_IVARS]
 
+// fileSet holds lookup results used for templates parsing
+type fileSet struct {
+	includes map[string]lookupfs.File
+	layouts  map[string]lookupfs.File
+	pages    map[string]lookupfs.File
+}
+
+// snapshot holds parsed templates with lookup results they were parsed from.
+// Snapshot is never changed after creation, reload replaces it as a whole,
+// so request goroutines use the same templates from start to finish.
+type snapshot struct {
+	files        fileSet
+	pageNames    []string // all of pages
+	visibleNames []string // pages without hidden ones (by name prefix, front matter or error pages)
+	pageLayouts  map[string]string
+	layouts      map[string]*templatePool
+	pages        map[string]*templatePool
+	includes     includeSet
+	usedFuncs    map[string]bool // funcs called by any template
+}
+
+// includeSet holds includes parsed by every engine in use with names used by include files
+type includeSet struct {
+	templates map[Engine]Template
+	deps      map[string]*templateDeps
+}
+
+// newSnapshot creates snapshot from templates parsed from lfs lookup results
+func newSnapshot(lfs *lookupfs.LookupFileSystem, includes includeSet, layouts, pages map[string]*templatePool) *snapshot {
+	pageLayouts := make(map[string]string, len(lfs.Pages))
+	for k := range lfs.Pages {
+		pageLayouts[k] = lfs.PageLayout(k)
+	}
+	var visibleNames []string
+	for _, k := range lfs.PageNames(true) {
+		if tp, ok := pages[k]; ok && tp.meta != nil && tp.meta.Hidden || isErrorPage(k) {
+			continue
+		}
+		visibleNames = append(visibleNames, k)
+	}
+	usedFuncs := map[string]bool{}
+	for _, d := range includes.deps {
+		addFuncs(usedFuncs, d)
+	}
+	for _, templates := range []map[string]*templatePool{layouts, pages} {
+		for _, tp := range templates {
+			addFuncs(usedFuncs, tp.deps)
+		}
+	}
+	return &snapshot{
+		files:        fileSet{includes: lfs.Includes, layouts: lfs.Layouts, pages: lfs.Pages},
+		pageNames:    lfs.PageNames(false),
+		visibleNames: visibleNames,
+		pageLayouts:  pageLayouts,
+		layouts:      layouts,
+		pages:        pages,
+		includes:     includes,
+		usedFuncs:    usedFuncs,
+	}
+}
+
+// snapshot returns templates in use
+func (tfs *TemplateService) snapshot() *snapshot {
+	if s := tfs.state.Load(); s != nil {
+		return s
+	}
+	return &snapshot{}
+}
+
 // New creates TemplateService with BufferPool of given size
 func New(size int) (tfs *TemplateService) {
 	tfs = &TemplateService{
 		funcMap: template.FuncMap{
-			"content": func() string { return "" },
+			"content":       func() string { return "" },
+			"block_content": noBlockContent,
+			"context":       context.Background,
 		},
-		bufPool: bpool.NewBufferPool(size),
+		bufPool:     bpool.NewBufferPool(size),
+		engine:      HTMLEngine{},
+		protoFuncs:  template.FuncMap{},
+		treeEngines: map[string]Engine{},
 	}
 	return tfs
 }
@@ -44,6 +137,8 @@ func New(size int) (tfs *TemplateService) {
 // LookupFS sets lookup filesystem
 func (tfs *TemplateService) LookupFS(fs *lookupfs.LookupFileSystem) *TemplateService {
 	tfs.lfs = fs
+	// lfs fields are changed on refresh, so its config value is kept here
+	tfs.defLayout = fs.DefaultLayout()
 	return tfs
 }
 
@@ -53,6 +148,28 @@ func (tfs *TemplateService) ParseAlways(flag bool) *TemplateService {
 	return tfs
 }
 
+// Stream enables rendering layout directly to writer.
+// Page content is rendered before layout as usual, but layout output is not buffered
+// and default content func writes rendered page content in place instead of returning it as string.
+// Layout execution error may leave partial output in writer in this mode.
+func (tfs *TemplateService) Stream(flag bool) *TemplateService {
+	tfs.stream = flag
+	return tfs
+}
+
+// Engine sets template engine used for all of templates (HTMLEngine by default)
+func (tfs *TemplateService) Engine(e Engine) *TemplateService {
+	tfs.engine = e
+	return tfs
+}
+
+// TreeEngine sets template engine used for pages and layouts within dir (like "mail" for "mail/welcome").
+// Includes are parsed by every engine in use
+func (tfs *TemplateService) TreeEngine(dir string, e Engine) *TemplateService {
+	tfs.treeEngines[strings.TrimSuffix(dir, "/")] = e
+	return tfs
+}
+
 // Funcs loads initial funcmap
 func (tfs *TemplateService) Funcs(funcMap template.FuncMap) *TemplateService {
 	for k, v := range funcMap {
@@ -65,86 +182,189 @@ func (tfs *TemplateService) Funcs(funcMap template.FuncMap) *TemplateService {
 }
 
 // PageNames returns page names for router setup
-func (tfs TemplateService) PageNames(hide bool) []string {
-	return tfs.lfs.PageNames(hide)
+func (tfs *TemplateService) PageNames(hide bool) []string {
+	s := tfs.snapshot()
+	if hide {
+		return append([]string(nil), s.visibleNames...)
+	}
+	return append([]string(nil), s.pageNames...)
+}
+
+// PageMeta returns page front matter if page has it
+func (tfs *TemplateService) PageMeta(name string) (lookupfs.FrontMatter, bool) {
+	tp, ok := tfs.snapshot().pages[name]
+	if !ok || tp.meta == nil {
+		return lookupfs.FrontMatter{}, false
+	}
+	return *tp.meta, true
+}
+
+// DefaultLayout returns default layout name
+func (tfs *TemplateService) DefaultLayout() string {
+	return tfs.defLayout
+}
+
+// PageVariant splits page name into base name and variant (see lookupfs.Variant)
+func (tfs *TemplateService) PageVariant(name string) (base, variant string) {
+	return tfs.lfs.Variant(name)
+}
+
+// PageMethods returns HTTP methods allowed for page by its front matter.
+// GET is allowed if front matter does not define methods
+func (tfs *TemplateService) PageMethods(name string) []string {
+	fm, ok := tfs.PageMeta(name)
+	if !ok || len(fm.Methods) == 0 {
+		return []string{http.MethodGet}
+	}
+	methods := make([]string, len(fm.Methods))
+	for i, m := range fm.Methods {
+		methods[i] = strings.ToUpper(m)
+	}
+	return methods
 }
 
 // Parse parses all of service templates
+// and starts filesystem watching if it was enabled by Watch
 func (tfs *TemplateService) Parse() (*TemplateService, error) {
+	if err := tfs.reload(); err != nil {
+		return nil, err
+	}
+	tfs.startWatch()
+	return tfs, nil
+}
+
+// reload looks up and parses all of service templates.
+// Templates in use are replaced only if all of them parsed successfully
+func (tfs *TemplateService) reload() error {
+	tfs.mu.Lock()
+	defer tfs.mu.Unlock()
 
 	err := tfs.lfs.LookupAll()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	includes, err := tfs.parseIncludes(tfs.lfs.Includes)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	layouts, err := tfs.parseTemplates(includes, tfs.lfs.Layouts)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	pages, err := tfs.parseTemplates(includes, tfs.lfs.Pages)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	tfs.baseTemplate = includes
-	tfs.layouts = *layouts
-	tfs.pages = *pages
-	return tfs, nil
+	tfs.state.Store(newSnapshot(tfs.lfs, includes, *layouts, *pages))
+	return nil
 }
 
-// parseIncludes parses included templates
-func (tfs TemplateService) parseIncludes(items map[string]lookupfs.File) (*template.Template, error) {
-	var t *template.Template
+// engines returns all of engines which engineFor may return, so includes are parsed by each of them once
+func (tfs *TemplateService) engines() []Engine {
+	engines := []Engine{tfs.engine}
+	seen := map[Engine]bool{tfs.engine: true}
+	for _, e := range tfs.treeEngines {
+		if !seen[e] {
+			seen[e] = true
+			engines = append(engines, e)
+		}
+	}
+	if seen[TextEngine{}] {
+		return engines
+	}
+	for _, v := range tfs.lfs.Variants() {
+		if v != "html" {
+			return append(engines, TextEngine{})
+		}
+	}
+	return engines
+}
+
+// engineFor returns engine for template with given name.
+// Engine of the closest tree is used if name is within any of TreeEngine dirs.
+// Otherwise all of variants except "html" are parsed by TextEngine, other templates - by service engine
+func (tfs *TemplateService) engineFor(name string) Engine {
+	base, variant := tfs.lfs.Variant(name)
+	for dir := base; dir != ""; {
+		if e, ok := tfs.treeEngines[dir]; ok {
+			return e
+		}
+		i := strings.LastIndex(dir, "/")
+		if i < 0 {
+			break
+		}
+		dir = dir[:i]
+	}
+	if variant != "" && variant != "html" {
+		return TextEngine{}
+	}
+	return tfs.engine
+}
 
+// parseIncludes parses included templates by every engine in use
+func (tfs *TemplateService) parseIncludes(items map[string]lookupfs.File) (includeSet, error) {
+	includes := includeSet{templates: map[Engine]Template{}, deps: map[string]*templateDeps{}}
 	for k, f := range items {
 		s, err := tfs.lfs.ReadFile(f.Path)
 		if err != nil {
-			return nil, err
+			return includeSet{}, err
 		}
-		var tmpl *template.Template
-		if t == nil {
-			t = template.New(k)
-			tmpl = t
-		} else {
-			tmpl = t.New(k)
+		var first Template
+		var before map[string]*parse.Tree
+		for _, e := range tfs.engines() {
+			var tmpl Template
+			if t, ok := includes.templates[e]; !ok {
+				tmpl = e.New(k)
+				includes.templates[e] = tmpl
+			} else {
+				tmpl = t.New(k)
+			}
+			if first == nil {
+				first, before = tmpl, setTrees(tmpl)
+			}
+			err = tmpl.Funcs(tfs.funcMap).Parse(s)
+			if err != nil {
+				return includeSet{}, err
+			}
 		}
-		_, err = tmpl.Funcs(tfs.funcMap).Parse(s)
-		if err != nil {
-			return nil, err
+		if includes.deps[k], err = fileDeps(k, s, first, before, tfs.funcMap); err != nil {
+			return includeSet{}, err
 		}
 	}
-	return t, nil
+	return includes, nil
 }
 
 // parseTemplates parses all page & layout templates
-func (tfs TemplateService) parseTemplates(includes *template.Template, items map[string]lookupfs.File) (*map[string]*template.Template, error) {
-	templates := map[string]*template.Template{}
+func (tfs *TemplateService) parseTemplates(includes includeSet, items map[string]lookupfs.File) (*map[string]*templatePool, error) {
+	templates := map[string]*templatePool{}
 	for k, f := range items {
-		tmpl, err := tfs.parseTemplate(includes, k, f)
+		tp, err := tfs.parseTemplate(includes, k, f)
 		if err != nil {
 			return nil, errors.Wrap(err, "parse template")
 		}
-		templates[k] = tmpl
+		templates[k] = tp
 	}
 	return &templates, nil
 }
 
-// parseTemplate parses single template
-func (tfs TemplateService) parseTemplate(includes *template.Template, k string, f lookupfs.File) (*template.Template, error) {
+// parseTemplate parses single template with its front matter (if any)
+func (tfs *TemplateService) parseTemplate(includes includeSet, k string, f lookupfs.File) (*templatePool, error) {
 	s, err := tfs.lfs.ReadFile(f.Path)
 	if err != nil {
 		return nil, err
 	}
-	t := includes
-	var tmpl *template.Template
-	if t == nil {
-		tmpl = template.New(k)
+	meta, s, err := lookupfs.ParseFrontMatter(s)
+	if err != nil {
+		return nil, errors.Wrap(err, k)
+	}
+	e := tfs.engineFor(k)
+	var tmpl Template
+	if t, ok := includes.templates[e]; !ok {
+		tmpl = e.New(k)
 	} else {
 		tmpl, err = t.Clone()
 		if err != nil {
@@ -152,19 +372,26 @@ func (tfs TemplateService) parseTemplate(includes *template.Template, k string,
 		}
 		tmpl = tmpl.New(k)
 	}
-	_, err = tmpl.Funcs(tfs.funcMap).Parse(s)
-	return tmpl, err
+	before := setTrees(tmpl)
+	if err = tmpl.Funcs(tfs.funcMap).Parse(s); err != nil {
+		return nil, err
+	}
+	tp := newTemplatePool(tmpl, tfs.funcMap)
+	tp.meta = meta
+	if tp.deps, err = fileDeps(k, s, tmpl, before, tfs.funcMap); err != nil {
+		return nil, err
+	}
+	return tp, nil
 }
 
-func (tfs TemplateService) parseTemplateWithDeps(items map[string]lookupfs.File, name string) (*template.Template, error) {
-	includes, err := tfs.parseIncludes(tfs.lfs.Includes)
+func (tfs *TemplateService) parseTemplateWithDeps(files fileSet, items map[string]lookupfs.File, name string, notFound func(string) error) (*templatePool, error) {
+	includes, err := tfs.parseIncludes(files.includes)
 	if err != nil {
 		return nil, err
 	}
 	f, ok := items[name]
 	if !ok {
-		err := fmt.Errorf("page %s does not exists", name)
-		return nil, err
+		return nil, notFound(name)
 	}
 	return tfs.parseTemplate(includes, name, f)
 }
@@ -176,58 +403,185 @@ type MetaData interface {
 	Layout() string // Return layout name
 }
 
+// LayoutSetter holds MetaData method which allows to change page layout.
+// If MetaData implements it and its layout is the default one, RenderContent replaces it with
+// page directory layout (see lookupfs.PageLayout) before page processing
+type LayoutSetter interface {
+	SetLayout(name string) string
+}
+
+// TitleSetter holds MetaData method which allows to set page title from front matter
+type TitleSetter interface {
+	SetTitle(name string) string
+}
+
+// ContentTypeSetter holds MetaData method which allows to set content type from front matter
+type ContentTypeSetter interface {
+	SetContentType(name string) string
+}
+
+// StatusSetter holds MetaData method which allows to set response status from front matter
+type StatusSetter interface {
+	SetStatus(status int) string
+}
+
+// setMetaDefaults sets MetaData attributes defined in page front matter.
+// Template still may change them while processing
+func setMetaDefaults(data MetaData, fm *lookupfs.FrontMatter) {
+	if s, ok := data.(LayoutSetter); ok && fm.Layout != "" {
+		s.SetLayout(fm.Layout)
+	}
+	if s, ok := data.(TitleSetter); ok && fm.Title != "" {
+		s.SetTitle(fm.Title)
+	}
+	if s, ok := data.(ContentTypeSetter); ok && fm.ContentType != "" {
+		s.SetContentType(fm.ContentType)
+	}
+	if s, ok := data.(StatusSetter); ok && fm.Status != 0 {
+		s.SetStatus(fm.Status)
+	}
+}
+
 // Execute renders page content and layout
-func (tfs TemplateService) Execute(wr io.Writer, name string, funcs template.FuncMap, data MetaData) error {
+func (tfs *TemplateService) Execute(wr io.Writer, name string, funcs template.FuncMap, data MetaData) error {
 	return tfs.Render(wr, funcs, data, tfs.RenderContent(name, funcs, data))
 }
 
-// RenderContent renders page content
-func (tfs TemplateService) RenderContent(name string, funcs template.FuncMap, data MetaData) *bytes.Buffer {
-	var tmpl *template.Template
-	var err error
+// RenderContent renders page content. Given funcs are not changed.
+// Page sections (templates defined in page) are available for layout via block_content func
+// when returned content is passed to Render
+func (tfs *TemplateService) RenderContent(name string, funcs template.FuncMap, data MetaData) *bytes.Buffer {
+	return tfs.renderContent(context.Background(), name, copyFuncs(funcs, 0), data)
+}
+
+// ReleaseContent returns content to buffer pool if it will not be passed to Render
+// (on redirect, for example)
+func (tfs *TemplateService) ReleaseContent(content *bytes.Buffer) {
+	if content == nil {
+		return
+	}
+	tfs.sections.take(content)
+	tfs.bufPool.Put(content)
+}
+
+// renderContent renders page content until ctx is done. funcs must be a copy owned by renderContent
+func (tfs *TemplateService) renderContent(ctx context.Context, name string, funcs template.FuncMap, data MetaData) *bytes.Buffer {
+	if err := ctx.Err(); err != nil {
+		data.SetError(err)
+		return nil
+	}
+	if tfs.strictFuncs {
+		if err := tfs.ValidateFuncs(funcs); err != nil {
+			data.SetError(err)
+			return nil
+		}
+	}
+	s := tfs.snapshot()
+	var tp *templatePool
 	if tfs.parseAlways {
-		tmpl, err = tfs.parseTemplateWithDeps(tfs.lfs.Pages, name)
+		var err error
+		tp, err = tfs.parseTemplateWithDeps(s.files, s.files.pages, name, pageNotFound)
 		if err != nil {
 			data.SetError(err)
 			return nil
 		}
 	} else {
 		var ok bool
-		tmpl, ok = tfs.pages[name] // TODO: tfs.Lookup(tfs.pages, name)
+		tp, ok = s.pages[name] // TODO: tfs.Lookup(tfs.pages, name)
 		if !ok {
-			err = fmt.Errorf("page %s does not exists", name)
-			data.SetError(err)
+			data.SetError(pageNotFound(name))
 			return nil
 		}
 	}
+	tmpl, err := tp.get()
+	if err != nil {
+		data.SetError(err)
+		return nil
+	}
+	defer tp.put(tmpl)
+	if _, variant := tfs.lfs.Variant(name); variant != "" {
+		if cs, ok := data.(ContentTypeSetter); ok {
+			if ctype := mime.TypeByExtension("." + variant); ctype != "" {
+				cs.SetContentType(ctype)
+			}
+		}
+	}
+	if ls, ok := data.(LayoutSetter); ok && data.Layout() == tfs.defLayout {
+		if layout, ok := s.pageLayouts[name]; ok && layout != data.Layout() {
+			ls.SetLayout(layout)
+		}
+	}
+	if tp.meta != nil {
+		setMetaDefaults(data, tp.meta)
+	}
+	lim := tfs.limits.withMeta(tp.meta)
+	lctx, cancel := lim.context(ctx)
+	defer cancel()
+	if lim.timeout > 0 {
+		// funcs may stop on deadline
+		funcs["context"] = func() context.Context { return lctx }
+	}
 	buf := tfs.bufPool.Get()
-	err = tmpl.Funcs(funcs).ExecuteTemplate(buf, name, data)
+	err = tmpl.Funcs(funcs).ExecuteTemplate(contextWriter(lctx, lim.writer(buf, 0)), name, data)
+	if err == nil {
+		// ctx may be done after last output
+		err = lctx.Err()
+	}
 	if err != nil {
 		tfs.bufPool.Put(buf)
-		data.SetError(err)
+		data.SetError(execError(lim.error(ctx, name, err)))
 		return nil
 	}
+	tfs.sections.set(buf, tfs.blockContent(tp, name, lim, funcs, data))
+	tfs.checkLayout(s, data)
 	return buf
 }
 
+// checkLayout sets ErrLayoutNotFound error if page layout does not exist,
+// so it is known before Render call
+func (tfs *TemplateService) checkLayout(s *snapshot, data MetaData) {
+	name := data.Layout()
+	if _, ok := s.files.layouts[name]; ok || name == "" {
+		return
+	}
+	data.SetError(layoutNotFound(name))
+}
+
 // layout returns metadata layout (if exists) or default layout otherwise
-func (tfs TemplateService) layout(name string, data MetaData) *template.Template {
-	tmpl, ok := tfs.layouts[name]
+func (tfs *TemplateService) layout(s *snapshot, name string, data MetaData) *templatePool {
+	tp, ok := s.layouts[name]
 	if !ok {
-		err := fmt.Errorf("layout %s does not exist", name)
-		data.SetError(err)
-		tmpl = tfs.layouts[tfs.lfs.DefaultLayout()]
+		data.SetError(layoutNotFound(name))
+		tp = s.layouts[tfs.defLayout]
 	}
-	return tmpl
+	return tp
 }
 
-// Render renders layout with prepared content
-func (tfs TemplateService) Render(w io.Writer, funcs template.FuncMap, data MetaData, content *bytes.Buffer) (err error) {
+// Render renders layout with prepared content.
+// Given funcs are not changed.
+// Layout may be wrapped into parent layout if it sets metadata layout name, like
+//
+//	{{ .SetLayout "default" }}
+//
+// In this case layout output is rendered as parent layout content.
+func (tfs *TemplateService) Render(w io.Writer, funcs template.FuncMap, data MetaData, content *bytes.Buffer) error {
+	// content funcs are set to the copy, so caller funcs are not changed
+	return tfs.render(context.Background(), w, copyFuncs(funcs, 3), data, content)
+}
 
+// render renders layout until ctx is done or limits are exceeded.
+// funcs must be a copy owned by render, layout content funcs are set to it
+func (tfs *TemplateService) render(ctx context.Context, w io.Writer, funcs template.FuncMap, data MetaData, content *bytes.Buffer) (err error) {
+	sections := tfs.sections.take(content)
+	s := tfs.snapshot()
 	name := data.Layout()
 	if name == "" {
 		// No layout needed
 		if content != nil {
+			if err := ctx.Err(); err != nil {
+				tfs.bufPool.Put(content)
+				return err
+			}
 			_, err := content.WriteTo(w)
 			tfs.bufPool.Put(content)
 			if err != nil {
@@ -236,33 +590,135 @@ func (tfs TemplateService) Render(w io.Writer, funcs template.FuncMap, data Meta
 		}
 		return nil
 	}
-	var tmpl *template.Template
+	lctx, cancel := tfs.limits.context(ctx)
+	defer cancel()
+	if tfs.limits.timeout > 0 {
+		// funcs may stop on deadline
+		funcs["context"] = func() context.Context { return lctx }
+	}
+	if sections != nil {
+		funcs["block_content"] = sections(lctx)
+	}
+	rendered := map[string]bool{}
+	for {
+		rendered[name] = true
+		lw := &layoutWriter{w: w, pool: tfs.bufPool, stream: tfs.stream, name: name, data: data}
+		var size int
+		if content != nil {
+			size = content.Len()
+		}
+		err = tfs.renderLayout(s, contextWriter(lctx, tfs.limits.writer(lw, size)), name, funcs, data, content)
+		if err == nil {
+			err = lctx.Err()
+		}
+		if content != nil {
+			tfs.bufPool.Put(content)
+		}
+		if err != nil {
+			lw.release()
+			return errors.Wrap(execError(tfs.limits.error(ctx, name, err)), "exec layout")
+		}
+		parent := data.Layout()
+		if parent == "" || parent == name {
+			return lw.flush()
+		}
+		if lw.direct {
+			return errors.Errorf("layout %s: parent layout %s must be set before output in stream mode", name, parent)
+		}
+		if rendered[parent] {
+			lw.release()
+			return errors.Errorf("layout %s: parent layout %s is already rendered", name, parent)
+		}
+		content = lw.buffer()
+		name = parent
+	}
+}
+
+// renderLayout renders single layout with prepared content
+func (tfs *TemplateService) renderLayout(s *snapshot, w io.Writer, name string, funcs template.FuncMap, data MetaData, content *bytes.Buffer) error {
+	var tp *templatePool
 	if tfs.parseAlways {
 		var err error
-		tmpl, err = tfs.parseTemplateWithDeps(tfs.lfs.Layouts, name)
+		tp, err = tfs.parseTemplateWithDeps(s.files, s.files.layouts, name, layoutNotFound)
 		if err != nil {
 			data.SetError(err)
 			// TODO: parse default layout?
-			tmpl = tfs.layouts[tfs.lfs.DefaultLayout()]
+			tp = s.layouts[tfs.defLayout]
 		}
 	} else {
-		tmpl = tfs.layout(name, data)
+		tp = tfs.layout(s, name, data)
 	}
-	buf := tfs.bufPool.Get()
-	defer tfs.bufPool.Put(buf)
+	tmpl, err := tp.get()
+	if err != nil {
+		return err
+	}
+	defer tp.put(tmpl)
 	if !tfs.useCustomContent && content != nil {
-		funcs["content"] = func() string { return content.String() }
+		if tfs.stream {
+			funcs["content"] = func() (string, error) {
+				_, err := w.Write(content.Bytes())
+				return "", err
+			}
+		} else {
+			funcs["content"] = func() string { return content.String() }
+		}
 	}
-	err = tmpl.Funcs(funcs).ExecuteTemplate(buf, name, data)
-	if content != nil {
-		tfs.bufPool.Put(content)
+	return tmpl.Funcs(funcs).ExecuteTemplate(w, name, data)
+}
+
+// layoutWriter holds output of single layout.
+// Output is buffered if layout has parent (so it will be the content of parent layout) or streaming is disabled,
+// otherwise it is written directly. Layout parent is checked at first write, so it must be set before any output.
+type layoutWriter struct {
+	w      io.Writer
+	buf    *bytes.Buffer
+	pool   *bpool.BufferPool
+	stream bool
+	name   string
+	data   MetaData
+	direct bool
+}
+
+// Write writes layout output
+func (lw *layoutWriter) Write(p []byte) (int, error) {
+	if lw.direct {
+		return lw.w.Write(p)
 	}
-	if err != nil {
-		return errors.Wrap(err, "exec layout")
+	if lw.buf == nil {
+		if parent := lw.data.Layout(); lw.stream && (parent == "" || parent == lw.name) {
+			lw.direct = true
+			return lw.w.Write(p)
+		}
+		lw.buf = lw.pool.Get()
 	}
-	_, err = buf.WriteTo(w)
+	return lw.buf.Write(p)
+}
+
+// buffer returns buffered layout output
+func (lw *layoutWriter) buffer() *bytes.Buffer {
+	if lw.buf == nil {
+		lw.buf = lw.pool.Get()
+	}
+	return lw.buf
+}
+
+// flush writes buffered output (if any)
+func (lw *layoutWriter) flush() error {
+	if lw.buf == nil {
+		return nil
+	}
+	defer lw.release()
+	_, err := lw.buf.WriteTo(lw.w)
 	if err != nil {
 		return errors.Wrap(err, "exec layout write")
 	}
 	return nil
 }
+
+// release returns buffer to pool
+func (lw *layoutWriter) release() {
+	if lw.buf != nil {
+		lw.pool.Put(lw.buf)
+		lw.buf = nil
+	}
+}
@@ -3,7 +3,9 @@ package apitpl
 import (
 	"bytes"
 	"html/template"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -58,7 +60,7 @@ func (ss *ServerSuite) TestPageNotExists() {
 	var b bytes.Buffer
 	err := ss.srv.Execute(&b, "page_unknown", template.FuncMap{}, page)
 	require.NoError(ss.T(), err)
-	assert.Equal(ss.T(), "<title>Error 0: Sorry</title>\npage page_unknown does not exists\n", b.String())
+	assert.Equal(ss.T(), "<title>Error 0: Sorry</title>\npage page_unknown does not exist\n", b.String())
 }
 func (ss *ServerSuite) TestLayoutNotExists() {
 	page := &samplemeta.Meta{}
@@ -67,3 +69,246 @@ func (ss *ServerSuite) TestLayoutNotExists() {
 	err := ss.srv.Execute(&b, "page", template.FuncMap{}, page)
 	assert.Equal(ss.T(), "exec layout: html/template: \"unknown\" is undefined", err.Error())
 }
+
+// chunkWriter stores every write separately
+type chunkWriter struct {
+	chunks []string
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	cw.chunks = append(cw.chunks, string(p))
+	return len(p), nil
+}
+
+func TestStream(t *testing.T) {
+	cfg := lookupfs.Config{
+		Includes:  "inc_minimal",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      "testdata",
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+	var want bytes.Buffer
+	err = tfs.Execute(&want, "subdir3/page", template.FuncMap{}, samplemeta.NewMeta(200, "text/html"))
+	require.NoError(t, err)
+
+	stfs, err := New(64).LookupFS(lookupfs.New(cfg)).Stream(true).Parse()
+	require.NoError(t, err)
+	var cw chunkWriter
+	err = stfs.Execute(&cw, "subdir3/page", template.FuncMap{}, samplemeta.NewMeta(200, "text/html"))
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), strings.Join(cw.chunks, ""))
+	assert.Contains(t, cw.chunks, "\npage2 here (inc2 here)", "content is written as is")
+
+	page := samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("subdir2/lay")
+	var b bytes.Buffer
+	err = stfs.Execute(&b, "page", template.FuncMap{}, page)
+	require.NoError(t, err)
+	assert.Equal(t, "lay2 here", b.String())
+}
+
+func TestNestedLayouts(t *testing.T) {
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      "testdata",
+	}
+	for _, stream := range []bool{false, true} {
+		tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Stream(stream).Parse()
+		require.NoError(t, err)
+		page := samplemeta.NewMeta(200, "text/html")
+		page.SetLayout("nested")
+		var b bytes.Buffer
+		err = tfs.Execute(&b, "page", template.FuncMap{}, page)
+		require.NoError(t, err)
+		assert.Equal(t, "<title>Default title</title>\n==[nested page1 here]\n==\n", b.String())
+		assert.Equal(t, "simple", page.Layout())
+	}
+}
+
+func TestNestedLayoutsErrors(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "layouts/loop1.html", `{{ .SetLayout "loop2" }}1{{ content }}`, mtime)
+	writeTemplate(t, dir, "layouts/loop2.html", `{{ .SetLayout "loop1" }}2{{ content }}`, mtime)
+	writeTemplate(t, dir, "layouts/late.html", `late{{ .SetLayout "default" }}{{ content }}`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page`, mtime)
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tests := []struct {
+		name   string
+		layout string
+		stream bool
+		want   string
+		err    string
+	}{
+		{name: "Loop", layout: "loop1", err: "layout loop2: parent layout loop1 is already rendered"},
+		{name: "LateParent", layout: "late", want: "[latepage]"},
+		{name: "LateParentStream", layout: "late", stream: true,
+			err: "layout late: parent layout default must be set before output in stream mode"},
+	}
+	for _, tt := range tests {
+		tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Stream(tt.stream).Parse()
+		require.NoError(t, err)
+		page := samplemeta.NewMeta(200, "text/html")
+		page.SetLayout(tt.layout)
+		var b bytes.Buffer
+		err = tfs.Execute(&b, "page", template.FuncMap{}, page)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, b.String(), tt.name)
+	}
+}
+
+func TestPageLayout(t *testing.T) {
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      "testdata",
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	page := samplemeta.NewMeta(200, "text/html")
+	var b bytes.Buffer
+	err = tfs.Execute(&b, "admin/users/list", template.FuncMap{}, page)
+	require.NoError(t, err)
+	assert.Equal(t, "[admin users list]\n", b.String())
+
+	// Layout set by caller is kept
+	page = samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("nested")
+	b.Reset()
+	err = tfs.Execute(&b, "admin/users/list", template.FuncMap{}, page)
+	require.NoError(t, err)
+	assert.Equal(t, "<title>Default title</title>\n==[nested users list]\n==\n", b.String())
+}
+
+func TestFrontMatter(t *testing.T) {
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      "testdata",
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	fm, ok := tfs.PageMeta("front")
+	require.True(t, ok)
+	assert.Equal(t, lookupfs.FrontMatter{Title: "Front matter", Layout: "simple", Status: 202, Hidden: true}, fm)
+	_, ok = tfs.PageMeta("page")
+	assert.False(t, ok, "page without front matter")
+	assert.Equal(t, []string{"GET"}, tfs.PageMethods("front"), "default methods")
+
+	assert.NotContains(t, tfs.PageNames(true), "front", "hidden page")
+	assert.Contains(t, tfs.PageNames(false), "front")
+
+	page := samplemeta.NewMeta(200, "text/html")
+	var b bytes.Buffer
+	err = tfs.Execute(&b, "front", template.FuncMap{}, page)
+	require.NoError(t, err)
+	assert.Equal(t, "<title>Front matter</title>\n==front Front matter\n==\n", b.String())
+	assert.Equal(t, 202, page.Status())
+}
+
+func TestFrontMatterLines(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `{{ content }}`, mtime)
+	writeTemplate(t, dir, "pages/page.html", "---\ntitle: Lines\n---\nline 4\n{{ .Unknown }}", mtime)
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+	page := samplemeta.NewMeta(200, "text/html")
+	tfs.RenderContent("page", template.FuncMap{}, page)
+	require.Error(t, page.Error())
+	assert.Contains(t, page.Error().Error(), `template: page:5:3: executing "page" at <.Unknown>`)
+}
+
+func TestVariants(t *testing.T) {
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      "testdata",
+		Variants:  "html,json",
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	base, variant := tfs.PageVariant("report.json")
+	assert.Equal(t, "report", base)
+	assert.Equal(t, "json", variant)
+
+	page := samplemeta.NewMeta(200, "text/html")
+	var b bytes.Buffer
+	err = tfs.Execute(&b, "report.json", template.FuncMap{}, page)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"report\": \"<b>\"}\n", b.String(), "text template without layout")
+	assert.Equal(t, "application/json", page.ContentType())
+}
+
+func TestEngines(t *testing.T) {
+	cfg := lookupfs.Config{Variants: "html"}
+	tfs := New(64).LookupFS(lookupfs.New(cfg))
+	assert.Equal(t, []Engine{HTMLEngine{}}, tfs.engines(), "includes are parsed once")
+	tfs.TreeEngine("mail", TextEngine{})
+	assert.Equal(t, []Engine{HTMLEngine{}, TextEngine{}}, tfs.engines())
+
+	cfg.Variants = "html,txt"
+	tfs = New(64).LookupFS(lookupfs.New(cfg))
+	assert.Equal(t, []Engine{HTMLEngine{}, TextEngine{}}, tfs.engines())
+}
+
+func TestEngine(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "includes/sign.html", `{{ define "sign" }}<team>{{ end }}`, mtime)
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "layouts/mail.html", `({{ content }}{{ template "sign" }})`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `{{ "<b>" }}`, mtime)
+	writeTemplate(t, dir, "pages/mail/welcome.html", `{{ .SetTitle "Hi" }}{{ "<b>" }} {{ .Title }}`, mtime)
+
+	cfg := lookupfs.Config{
+		Includes:  "includes",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).TreeEngine("mail", TextEngine{}).Parse()
+	require.NoError(t, err)
+	assert.NotContains(t, renderPage(tfs, "page"), "<b>", "html engine escapes output")
+	assert.Equal(t, "(<b> Hi<team>)", renderPage(tfs, "mail/welcome"))
+
+	tfs, err = New(64).LookupFS(lookupfs.New(cfg)).Engine(TextEngine{}).Parse()
+	require.NoError(t, err)
+	assert.Equal(t, "[<b>]", renderPage(tfs, "page"))
+}
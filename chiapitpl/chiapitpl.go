@@ -0,0 +1,64 @@
+// Package chiapitpl implements a chi frontend for apitpl.
+package chiapitpl
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/birkirb/loggers.v1"
+
+	"github.com/apisite/apitpl/frontend"
+)
+
+// MetaData holds template metadata access methods
+type MetaData = frontend.MetaData
+
+// TemplateService allows to replace apitpl functionality with the other package
+type TemplateService = frontend.TemplateService
+
+// Template holds template engine attributes
+type Template struct {
+	// RequestHandler returns page metadata. Page params are available via chi.URLParam
+	RequestHandler func(r *http.Request, funcs template.FuncMap) MetaData
+	core           *frontend.Frontend
+}
+
+// New creates template object
+func New(log loggers.Contextual, fs TemplateService) *Template {
+	return &Template{core: frontend.New(log, fs)}
+}
+
+// Route registers template routes into chi router.
+// Page params (like ":id" for "__id" directory) are registered as "{id}"
+func (tmpl Template) Route(prefix string, r chi.Router) {
+	for _, rt := range tmpl.core.Routes() {
+		r.Method(rt.Method, frontend.Path(prefix, rt.Name, chiParam), tmpl.handle(rt.Handler))
+	}
+}
+
+// chiParam returns chi route param
+func chiParam(name string) string {
+	return "{" + name + "}"
+}
+
+// HTML renders page for given uri with request.
+// Request form is parsed before RequestHandler call for methods other than GET and HEAD
+func (tmpl Template) HTML(w http.ResponseWriter, r *http.Request, uri string) {
+	tmpl.handle(tmpl.core.Handler(uri))(w, r)
+}
+
+// ErrorHandler returns handler which renders error page for given status (e.g. for r.NotFound).
+// Plain status text is written if there is no such page
+func (tmpl Template) ErrorHandler(status int) http.HandlerFunc {
+	return tmpl.handle(tmpl.core.ErrorHandler(status))
+}
+
+// handle returns http handler which passes chi route params to frontend handler
+func (tmpl Template) handle(h frontend.PageHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		get := func(name string) string { return chi.URLParam(r, name) }
+		r = r.WithContext(frontend.WithParams(r.Context(), get))
+		h(w, r, tmpl.RequestHandler)
+	}
+}
@@ -0,0 +1,25 @@
+package chiapitpl
+
+import (
+	"html/template"
+	"net/http"
+	"testing"
+
+	mapper "github.com/birkirb/loggers-mapper-logrus"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/apisite/apitpl/ginapitpl/samplemeta"
+	"github.com/apisite/apitpl/internal/routertest"
+)
+
+func TestRoute(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	tmpl := New(mapper.NewLogger(l), routertest.Service(t))
+	tmpl.RequestHandler = func(r *http.Request, funcs template.FuncMap) MetaData {
+		return samplemeta.NewMeta(http.StatusOK, "text/html; charset=utf-8")
+	}
+	r := chi.NewRouter()
+	tmpl.Route(routertest.Prefix, r)
+	routertest.Run(t, r)
+}
@@ -0,0 +1,89 @@
+// apitpl-export renders site pages into static files.
+//
+// Usage:
+//
+//	apitpl-export [flags] output_dir
+//
+// Flags are lookupfs.Config fields and stubs of request funcs, see apitpl-export --help.
+// Templates are processed with ginapitpl/samplemeta.Meta as metadata and HTML func
+// (which marks string as safe HTML), use package export for custom metadata and funcs.
+// Funcs which are bound to request at run time may be replaced by stubs returning given value, like
+//
+//	apitpl-export --stub user:Guest --stub token: public
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/export"
+	"github.com/apisite/apitpl/ginapitpl/samplemeta"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+// Config holds all config vars
+type Config struct {
+	Stubs map[string]string `long:"stub" description:"Template func stub as name:value, func returns value for any args"`
+	Args  struct {
+		Dir string `positional-arg-name:"output_dir" required:"yes" description:"Output directory"`
+	} `positional-args:"yes"`
+	lookupfs.Config `group:"Templates"`
+}
+
+func main() {
+	cfg := Config{}
+	if _, err := flags.Parse(&cfg); err != nil {
+		var e *flags.Error
+		if errors.As(err, &e) && e.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	os.Exit(run(cfg))
+}
+
+// run exports pages and prints report. It returns process exit code
+func run(cfg Config) int {
+	funcs := template.FuncMap{
+		"HTML": func(s string) template.HTML { return template.HTML(s) },
+	}
+	for name, value := range cfg.Stubs {
+		funcs[name] = stub(value)
+	}
+	tfs, err := apitpl.New(64).Funcs(funcs).LookupFS(lookupfs.New(cfg.Config)).Parse()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Parse error:", err)
+		return 1
+	}
+	newMeta := func(page string) export.MetaData { return samplemeta.NewMeta(200, "text/html; charset=utf-8") }
+	report, err := export.New(tfs, newMeta).Export(cfg.Args.Dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Export error:", err)
+		return 1
+	}
+	fmt.Printf("Exported %d pages, %d redirects, skipped %d parameterized pages\n",
+		len(report.Pages), len(report.Redirects), len(report.Skipped))
+	if len(report.Errors) == 0 {
+		return 0
+	}
+	pages := make([]string, 0, len(report.Errors))
+	for page := range report.Errors {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	for _, page := range pages {
+		fmt.Fprintf(os.Stderr, "Page %s error: %v\n", page, report.Errors[page])
+	}
+	return 1
+}
+
+// stub returns template func which returns value for any args
+func stub(value string) func(args ...interface{}) string {
+	return func(args ...interface{}) string { return value }
+}
@@ -0,0 +1,42 @@
+// apitpl-lint checks site templates for errors which otherwise are found at request time.
+//
+// Usage:
+//
+//	apitpl-lint [flags]
+//
+// Flags are lookupfs.Config fields, see apitpl-lint --help.
+// Issues are printed as "path:line: message", exit code is 1 if any issue was found.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/apisite/apitpl/lint"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+func main() {
+	cfg := lookupfs.Config{}
+	if _, err := flags.Parse(&cfg); err != nil {
+		var e *flags.Error
+		if errors.As(err, &e) && e.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	issues, err := lint.Lint(lookupfs.New(cfg), nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Lookup error:", err)
+		os.Exit(1)
+	}
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) != 0 {
+		os.Exit(1)
+	}
+}
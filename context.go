@@ -0,0 +1,55 @@
+package apitpl
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+)
+
+// ExecuteContext renders page content and layout until ctx is done
+func (tfs *TemplateService) ExecuteContext(ctx context.Context, wr io.Writer, name string, funcs template.FuncMap, data MetaData) error {
+	return tfs.RenderContext(ctx, wr, funcs, data, tfs.RenderContentContext(ctx, name, funcs, data))
+}
+
+// RenderContentContext renders page content like RenderContent, but stops on first output after ctx is done
+// and sets ctx error via MetaData.SetError in this case (or if ctx is done when page is rendered).
+// Template funcs are not interrupted, so long running funcs should check ctx from context func themselves.
+// ctx is available for templates via context func, so funcs may get request scoped values like {{ user context }}.
+// Given funcs are not changed, so layout gets ctx via RenderContext.
+func (tfs *TemplateService) RenderContentContext(ctx context.Context, name string, funcs template.FuncMap, data MetaData) *bytes.Buffer {
+	funcs = copyFuncs(funcs, 1)
+	funcs["context"] = func() context.Context { return ctx }
+	return tfs.renderContent(ctx, name, funcs, data)
+}
+
+// RenderContext renders layout with prepared content like Render, but stops on first output after ctx is done
+// and returns ctx error if ctx is done when layout is rendered.
+// ctx is available for templates via context func
+func (tfs *TemplateService) RenderContext(ctx context.Context, w io.Writer, funcs template.FuncMap, data MetaData, content *bytes.Buffer) error {
+	funcs = copyFuncs(funcs, 3)
+	funcs["context"] = func() context.Context { return ctx }
+	return tfs.render(ctx, w, funcs, data, content)
+}
+
+// ctxWriter fails writing if its context is done
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// contextWriter returns writer which fails if ctx is done. w is returned as is if ctx is never done
+func contextWriter(ctx context.Context, w io.Writer) io.Writer {
+	if ctx.Done() == nil {
+		return w
+	}
+	return ctxWriter{ctx: ctx, w: w}
+}
+
+// Write writes p if context is not done
+func (cw ctxWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
@@ -0,0 +1,84 @@
+package apitpl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+func TestExecuteContext(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]{{ user context }}`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `{{ user context }}`, mtime)
+	writeTemplate(t, dir, "pages/loop.html", `{{ range $i := seq }}{{ $i }}{{ if eq $i 2 }}{{ stop }}{{ end }}{{ end }}`, mtime)
+	writeTemplate(t, dir, "pages/quiet.html", `{{ range $i := seq }}{{ if eq $i 2 }}{{ stop }}{{ end }}{{ end }}`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	var cancel context.CancelFunc
+	funcs := template.FuncMap{
+		"user": func(ctx context.Context) string {
+			if u, ok := ctx.Value(userKey{}).(string); ok {
+				return u
+			}
+			return "guest"
+		},
+		"seq":  func() []int { return []int{1, 2, 3, 4} },
+		"stop": func() string { cancel(); return "" },
+	}
+	tfs, err := New(64).Funcs(funcs).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), userKey{}, "Joe")
+	page := samplemeta.NewMeta(200, "text/html")
+	var b bytes.Buffer
+	require.NoError(t, tfs.ExecuteContext(ctx, &b, "page", template.FuncMap{}, page))
+	require.NoError(t, page.Error())
+	assert.Equal(t, "[Joe]Joe", b.String(), "context func")
+
+	b.Reset()
+	require.NoError(t, tfs.Execute(&b, "page", template.FuncMap{}, page))
+	assert.Equal(t, "[guest]guest", b.String(), "default context")
+
+	// Cancellation while page is rendered
+	ctx, cancel = context.WithCancel(context.Background())
+	page = samplemeta.NewMeta(200, "text/html")
+	content := tfs.RenderContentContext(ctx, "loop", template.FuncMap{}, page)
+	assert.Nil(t, content)
+	assert.True(t, errors.Is(page.Error(), context.Canceled))
+
+	// Cancellation without following output is reported after page is rendered
+	ctx, cancel = context.WithCancel(context.Background())
+	page = samplemeta.NewMeta(200, "text/html")
+	content = tfs.RenderContentContext(ctx, "quiet", nil, page)
+	assert.Nil(t, content)
+	assert.True(t, errors.Is(page.Error(), context.Canceled))
+
+	// Cancelled context stops layout rendering
+	page = samplemeta.NewMeta(200, "text/html")
+	ctx, cancel = context.WithCancel(context.Background())
+	funcs = template.FuncMap{}
+	content = tfs.RenderContentContext(ctx, "page", funcs, page)
+	require.NotNil(t, content)
+	assert.Empty(t, funcs, "caller funcs are not changed")
+	cancel()
+	b.Reset()
+	err = tfs.RenderContext(ctx, &b, funcs, page, content)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, b.String())
+}
@@ -0,0 +1,225 @@
+package apitpl
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"text/template/parse"
+
+	"github.com/apisite/apitpl/internal/parsetree"
+)
+
+// templateDeps holds names used by template file
+type templateDeps struct {
+	defines []string // templates defined in file
+	refs    []string // templates referenced in file and not defined there
+	funcs   []string // funcmap funcs called in file
+}
+
+// Deps holds template dependencies
+type Deps struct {
+	Includes []string `json:"includes,omitempty"` // Includes referenced by template
+	Funcs    []string `json:"funcs,omitempty"`    // Funcs called by template
+}
+
+// Graph holds dependencies of all templates
+type Graph struct {
+	Includes map[string]Deps `json:"includes"`
+	Layouts  map[string]Deps `json:"layouts"`
+	Pages    map[string]Deps `json:"pages"`
+}
+
+// treeSet holds optional Template method which returns parse trees of all templates in its set.
+// Dependencies of templates which do not implement it are found by parsing their text again
+type treeSet interface {
+	Trees() map[string]*parse.Tree
+}
+
+// setTrees returns parse trees of tmpl set (nil if tmpl does not implement treeSet)
+func setTrees(tmpl Template) map[string]*parse.Tree {
+	if ts, ok := tmpl.(treeSet); ok {
+		return ts.Trees()
+	}
+	return nil
+}
+
+// fileDeps returns names of templates and funcs used by file which is parsed into tmpl set.
+// File trees are the ones added or replaced since before trees were taken
+func fileDeps(name, text string, tmpl Template, before map[string]*parse.Tree, funcs template.FuncMap) (*templateDeps, error) {
+	ts, ok := tmpl.(treeSet)
+	if !ok {
+		return parseDeps(name, text, funcs)
+	}
+	trees := map[string]*parse.Tree{}
+	for k, t := range ts.Trees() {
+		if before[k] != t {
+			trees[k] = t
+		}
+	}
+	return treeDeps(name, trees, funcs), nil
+}
+
+// parseDeps parses template text and returns names of templates and funcs it uses
+func parseDeps(name, text string, funcs template.FuncMap) (*templateDeps, error) {
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := tree.Parse(text, "", "", trees); err != nil {
+		return nil, err
+	}
+	return treeDeps(name, trees, funcs), nil
+}
+
+// treeDeps walks parse trees of template file and returns names of templates and funcs it uses.
+// Only funcs from funcs map are returned, so builtins are skipped
+func treeDeps(name string, trees map[string]*parse.Tree, funcs template.FuncMap) *templateDeps {
+	defined := map[string]bool{name: true}
+	for k := range trees {
+		defined[k] = true
+	}
+	refs, called := map[string]bool{}, map[string]bool{}
+	for _, t := range trees {
+		parsetree.Walk(t.Root, func(node parse.Node) {
+			switch n := node.(type) {
+			case *parse.TemplateNode:
+				if !defined[n.Name] {
+					refs[n.Name] = true
+				}
+			case *parse.IdentifierNode:
+				if _, ok := funcs[n.Ident]; ok {
+					called[n.Ident] = true
+				}
+			}
+		})
+	}
+	return &templateDeps{defines: setKeys(defined), refs: setKeys(refs), funcs: setKeys(called)}
+}
+
+// setKeys returns sorted keys of set
+func setKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Graph returns dependencies of templates in use.
+// Template references are resolved to include names, references to undefined templates are skipped
+func (tfs *TemplateService) Graph() *Graph {
+	s := tfs.snapshot()
+	// include which defines every template name
+	index := map[string]string{}
+	for k, d := range s.includes.deps {
+		for _, name := range d.defines {
+			index[name] = k
+		}
+	}
+	resolve := func(d *templateDeps) Deps {
+		if d == nil {
+			return Deps{}
+		}
+		includes := map[string]bool{}
+		for _, ref := range d.refs {
+			if k, ok := index[ref]; ok {
+				includes[k] = true
+			}
+		}
+		return Deps{Includes: setKeys(includes), Funcs: d.funcs}
+	}
+	g := &Graph{Includes: map[string]Deps{}, Layouts: map[string]Deps{}, Pages: map[string]Deps{}}
+	for k, d := range s.includes.deps {
+		g.Includes[k] = resolve(d)
+	}
+	for k, tp := range s.layouts {
+		g.Layouts[k] = resolve(tp.deps)
+	}
+	for k, tp := range s.pages {
+		g.Pages[k] = resolve(tp.deps)
+	}
+	return g
+}
+
+// Dependents returns layouts and pages which use include directly or via other includes
+func (g Graph) Dependents(include string) (layouts, pages []string) {
+	affected := map[string]bool{include: true}
+	for changed := true; changed; {
+		changed = false
+		for k, d := range g.Includes {
+			if !affected[k] && usesAny(d, affected) {
+				affected[k] = true
+				changed = true
+			}
+		}
+	}
+	return dependents(g.Layouts, affected), dependents(g.Pages, affected)
+}
+
+// UnusedIncludes returns includes which are not used by any layout or page
+func (g Graph) UnusedIncludes() []string {
+	var unused []string
+	for k := range g.Includes {
+		if layouts, pages := g.Dependents(k); len(layouts) == 0 && len(pages) == 0 {
+			unused = append(unused, k)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// WriteDot writes graph of template references in graphviz dot format
+func (g Graph) WriteDot(w io.Writer) error {
+	if _, err := io.WriteString(w, "digraph templates {\n"); err != nil {
+		return err
+	}
+	for _, kind := range []struct {
+		prefix string
+		deps   map[string]Deps
+	}{{"include", g.Includes}, {"layout", g.Layouts}, {"page", g.Pages}} {
+		names := make([]string, 0, len(kind.deps))
+		for k := range kind.deps {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			node := fmt.Sprintf("%q", kind.prefix+":"+k)
+			if _, err := fmt.Fprintf(w, "  %s;\n", node); err != nil {
+				return err
+			}
+			for _, inc := range kind.deps[k].Includes {
+				if _, err := fmt.Fprintf(w, "  %s -> %q;\n", node, "include:"+inc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// usesAny returns true if deps contain any of includes
+func usesAny(d Deps, includes map[string]bool) bool {
+	for _, k := range d.Includes {
+		if includes[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// dependents returns sorted names of templates which use any of includes
+func dependents(templates map[string]Deps, includes map[string]bool) []string {
+	var names []string
+	for k, d := range templates {
+		if usesAny(d, includes) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,108 @@
+package apitpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+// noTreesEngine creates html templates which do not implement treeSet
+type noTreesEngine struct{}
+
+func (noTreesEngine) New(name string) Template { return noTrees{HTMLEngine{}.New(name)} }
+
+// noTrees hides Trees method of template
+type noTrees struct {
+	Template
+}
+
+func (t noTrees) New(name string) Template { return noTrees{t.Template.New(name)} }
+
+func (t noTrees) Funcs(funcs template.FuncMap) Template { return noTrees{t.Template.Funcs(funcs)} }
+
+func (t noTrees) Clone() (Template, error) {
+	c, err := t.Template.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return noTrees{c}, nil
+}
+
+func TestGraph(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "includes/menu.html", `{{ define "menu" }}<nav>{{ template "item" }}{{ template "local" }}</nav>{{ end }}
+{{ define "local" }}local{{ end }}`, mtime)
+	writeTemplate(t, dir, "includes/item.html", `{{ define "item" }}{{ link "/" }}{{ end }}`, mtime)
+	writeTemplate(t, dir, "includes/unused.html", `unused`, mtime)
+	writeTemplate(t, dir, "layouts/default.html", `{{ template "menu" }}{{ content }}`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `{{ template "item" }}{{ if true }}{{ user | printf "%s" }}{{ end }}`, mtime)
+	writeTemplate(t, dir, "pages/plain.html", `{{ define "own" }}own{{ end }}{{ template "own" }}{{ template "undefined" }}`, mtime)
+
+	cfg := lookupfs.Config{
+		Includes:  "includes",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	funcs := template.FuncMap{
+		"link": func(s string) string { return s },
+		"user": func() string { return "" },
+	}
+	tfs, err := New(64).Funcs(funcs).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	g := tfs.Graph()
+	assert.Equal(t, Graph{
+		Includes: map[string]Deps{
+			"item":   {Funcs: []string{"link"}},
+			"menu":   {Includes: []string{"item"}},
+			"unused": {},
+		},
+		Layouts: map[string]Deps{
+			"default": {Includes: []string{"menu"}, Funcs: []string{"content"}},
+		},
+		Pages: map[string]Deps{
+			"page":  {Includes: []string{"item"}, Funcs: []string{"user"}},
+			"plain": {},
+		},
+	}, *g)
+
+	// Templates without parse trees access have the same deps
+	tfs, err = New(64).Funcs(funcs).LookupFS(lookupfs.New(cfg)).Engine(noTreesEngine{}).Parse()
+	require.NoError(t, err)
+	assert.Equal(t, g, tfs.Graph())
+
+	layouts, pages := g.Dependents("item")
+	assert.Equal(t, []string{"default"}, layouts)
+	assert.Equal(t, []string{"page"}, pages)
+	assert.Equal(t, []string{"unused"}, g.UnusedIncludes())
+
+	var b bytes.Buffer
+	require.NoError(t, g.WriteDot(&b))
+	assert.Equal(t, `digraph templates {
+  "include:item";
+  "include:menu";
+  "include:menu" -> "include:item";
+  "include:unused";
+  "layout:default";
+  "layout:default" -> "include:menu";
+  "page:page";
+  "page:page" -> "include:item";
+  "page:plain";
+}
+`, b.String())
+
+	data, err := json.Marshal(g.Pages)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"page":{"includes":["item"],"funcs":["user"]},"plain":{}}`, string(data))
+}
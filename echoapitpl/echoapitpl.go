@@ -0,0 +1,71 @@
+// Package echoapitpl implements an echo frontend for apitpl.
+package echoapitpl
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/birkirb/loggers.v1"
+
+	"github.com/apisite/apitpl/frontend"
+)
+
+// MetaData holds template metadata access methods
+type MetaData = frontend.MetaData
+
+// TemplateService allows to replace apitpl functionality with the other package
+type TemplateService = frontend.TemplateService
+
+// Router holds route registering method implemented by echo.Echo and echo.Group
+type Router interface {
+	Add(method, path string, handler echo.HandlerFunc, middleware ...echo.MiddlewareFunc) *echo.Route
+}
+
+// Template holds template engine attributes
+type Template struct {
+	// RequestHandler returns page metadata. Page params are available via c.Param
+	RequestHandler func(c echo.Context, funcs template.FuncMap) MetaData
+	core           *frontend.Frontend
+}
+
+// New creates template object
+func New(log loggers.Contextual, fs TemplateService) *Template {
+	return &Template{core: frontend.New(log, fs)}
+}
+
+// Route registers template routes into echo router
+func (tmpl Template) Route(prefix string, r Router) {
+	for _, rt := range tmpl.core.Routes() {
+		r.Add(rt.Method, frontend.Path(prefix, rt.Name, echoParam), tmpl.handle(rt.Handler))
+	}
+}
+
+// echoParam returns echo route param
+func echoParam(name string) string {
+	return ":" + name
+}
+
+// HTML renders page for given uri with context.
+// Request form is parsed before RequestHandler call for methods other than GET and HEAD
+func (tmpl Template) HTML(c echo.Context, uri string) error {
+	return tmpl.handle(tmpl.core.Handler(uri))(c)
+}
+
+// ErrorHandler returns handler which renders error page for given status (e.g. for e.RouteNotFound).
+// Plain status text is written if there is no such page
+func (tmpl Template) ErrorHandler(status int) echo.HandlerFunc {
+	return tmpl.handle(tmpl.core.ErrorHandler(status))
+}
+
+// handle returns echo handler which passes echo route params to frontend handler
+func (tmpl Template) handle(h frontend.PageHandler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := c.Request()
+		c.SetRequest(r.WithContext(frontend.WithParams(r.Context(), c.Param)))
+		h(c.Response(), c.Request(), func(r *http.Request, funcs template.FuncMap) MetaData {
+			return tmpl.RequestHandler(c, funcs)
+		})
+		return nil
+	}
+}
@@ -0,0 +1,25 @@
+package echoapitpl
+
+import (
+	"html/template"
+	"net/http"
+	"testing"
+
+	mapper "github.com/birkirb/loggers-mapper-logrus"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/apisite/apitpl/ginapitpl/samplemeta"
+	"github.com/apisite/apitpl/internal/routertest"
+)
+
+func TestRoute(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	tmpl := New(mapper.NewLogger(l), routertest.Service(t))
+	tmpl.RequestHandler = func(c echo.Context, funcs template.FuncMap) MetaData {
+		return samplemeta.NewMeta(http.StatusOK, "text/html; charset=utf-8")
+	}
+	r := echo.New()
+	tmpl.Route("", r.Group(routertest.Prefix))
+	routertest.Run(t, r)
+}
@@ -0,0 +1,118 @@
+package apitpl
+
+import (
+	"html/template"
+	"io"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// Engine creates templates of some template package.
+// Engine is used as map key, so it must be comparable
+type Engine interface {
+	New(name string) Template
+}
+
+// Template holds template methods used by TemplateService
+type Template interface {
+	New(name string) Template
+	Funcs(funcs template.FuncMap) Template
+	Parse(text string) error
+	Clone() (Template, error)
+	Defined(name string) bool
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// HTMLEngine creates html/template templates. It is the default engine
+type HTMLEngine struct{}
+
+// New creates html/template template
+func (HTMLEngine) New(name string) Template { return htmlTemplate{template.New(name)} }
+
+// htmlTemplate wraps html/template template
+type htmlTemplate struct {
+	t *template.Template
+}
+
+func (ht htmlTemplate) New(name string) Template { return htmlTemplate{ht.t.New(name)} }
+
+func (ht htmlTemplate) Funcs(funcs template.FuncMap) Template {
+	ht.t.Funcs(funcs)
+	return ht
+}
+
+func (ht htmlTemplate) Parse(text string) error {
+	_, err := ht.t.Parse(text)
+	return err
+}
+
+func (ht htmlTemplate) Clone() (Template, error) {
+	t, err := ht.t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return htmlTemplate{t}, nil
+}
+
+func (ht htmlTemplate) Defined(name string) bool { return ht.t.Lookup(name) != nil }
+
+func (ht htmlTemplate) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	return ht.t.ExecuteTemplate(w, name, data)
+}
+
+func (ht htmlTemplate) Trees() map[string]*parse.Tree {
+	trees := map[string]*parse.Tree{}
+	for _, t := range ht.t.Templates() {
+		if t.Tree != nil {
+			trees[t.Name()] = t.Tree
+		}
+	}
+	return trees
+}
+
+// TextEngine creates text/template templates, their output is not escaped
+type TextEngine struct{}
+
+// New creates text/template template
+func (TextEngine) New(name string) Template { return textTemplate{texttemplate.New(name)} }
+
+// textTemplate wraps text/template template
+type textTemplate struct {
+	t *texttemplate.Template
+}
+
+func (tt textTemplate) New(name string) Template { return textTemplate{tt.t.New(name)} }
+
+func (tt textTemplate) Funcs(funcs template.FuncMap) Template {
+	tt.t.Funcs(texttemplate.FuncMap(funcs))
+	return tt
+}
+
+func (tt textTemplate) Parse(text string) error {
+	_, err := tt.t.Parse(text)
+	return err
+}
+
+func (tt textTemplate) Clone() (Template, error) {
+	t, err := tt.t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return textTemplate{t}, nil
+}
+
+func (tt textTemplate) Defined(name string) bool { return tt.t.Lookup(name) != nil }
+
+func (tt textTemplate) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	return tt.t.ExecuteTemplate(w, name, data)
+}
+
+func (tt textTemplate) Trees() map[string]*parse.Tree {
+	trees := map[string]*parse.Tree{}
+	for _, t := range tt.t.Templates() {
+		if t.Tree != nil {
+			trees[t.Name()] = t.Tree
+		}
+	}
+	return trees
+}
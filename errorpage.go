@@ -0,0 +1,28 @@
+package apitpl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ErrorPrefix holds name prefix of error pages like "_error/404" or "_error/5xx".
+// Error pages are hidden, so routes for them are not registered
+const ErrorPrefix = "_error/"
+
+// ErrorPage returns name of page rendered for response status.
+// Page for status ("_error/404") is used if exists, page for status class ("_error/4xx") otherwise
+func (tfs *TemplateService) ErrorPage(status int) (string, bool) {
+	s := tfs.snapshot()
+	code := strconv.Itoa(status)
+	for _, name := range []string{ErrorPrefix + code, ErrorPrefix + code[:1] + "xx"} {
+		if _, ok := s.files.pages[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// isErrorPage returns true if page is error page
+func isErrorPage(name string) bool {
+	return strings.HasPrefix(name, ErrorPrefix)
+}
@@ -0,0 +1,48 @@
+package apitpl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+func TestErrorPage(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `{{ content }}`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page`, mtime)
+	writeTemplate(t, dir, "pages/_error/404.html", `not found`, mtime)
+	writeTemplate(t, dir, "pages/_error/4xx.html", `client error`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:    "layouts",
+		Pages:      "pages",
+		Ext:        ".html",
+		DefLayout:  "default",
+		Root:       dir,
+		HidePrefix: ".",
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	tests := []struct {
+		status int
+		name   string
+		ok     bool
+	}{
+		{status: 404, name: "_error/404", ok: true},
+		{status: 403, name: "_error/4xx", ok: true},
+		{status: 500},
+	}
+	for _, tt := range tests {
+		name, ok := tfs.ErrorPage(tt.status)
+		assert.Equal(t, tt.ok, ok, tt.status)
+		assert.Equal(t, tt.name, name, tt.status)
+	}
+	assert.Equal(t, []string{"page"}, tfs.PageNames(true), "error pages are hidden")
+	assert.Equal(t, []string{"_error/404", "_error/4xx", "page"}, tfs.PageNames(false))
+}
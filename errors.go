@@ -0,0 +1,70 @@
+package apitpl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	ttemplate "text/template"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrPageNotFound is set via MetaData.SetError when page does not exist
+	ErrPageNotFound = errors.New("page not found")
+	// ErrLayoutNotFound is set via MetaData.SetError when layout does not exist
+	ErrLayoutNotFound = errors.New("layout not found")
+)
+
+// notFoundError holds name of page or layout which does not exist
+type notFoundError struct {
+	kind string // "page" or "layout"
+	name string
+	err  error
+}
+
+// pageNotFound returns ErrPageNotFound error for page name
+func pageNotFound(name string) error {
+	return &notFoundError{kind: "page", name: name, err: ErrPageNotFound}
+}
+
+// layoutNotFound returns ErrLayoutNotFound error for layout name
+func layoutNotFound(name string) error {
+	return &notFoundError{kind: "layout", name: name, err: ErrLayoutNotFound}
+}
+
+// Error returns error message
+func (e *notFoundError) Error() string { return fmt.Sprintf("%s %s does not exist", e.kind, e.name) }
+
+// Unwrap returns ErrPageNotFound or ErrLayoutNotFound
+func (e *notFoundError) Unwrap() error { return e.err }
+
+// ExecError is set via MetaData.SetError (or returned by Render) when template execution fails
+type ExecError struct {
+	Name string // name of template where error occurred
+	Line int    // template line (0 if unknown)
+	Err  error  // text/template execution error
+}
+
+// Error returns execution error message
+func (e *ExecError) Error() string { return e.Err.Error() }
+
+// Unwrap returns execution error, so errors returned by template funcs are available via errors.Is/As
+func (e *ExecError) Unwrap() error { return e.Err }
+
+// reExecLocation matches execution error location like "template: name:12:3: "
+var reExecLocation = regexp.MustCompile(`^template: (.*?):(\d+):\d+: `)
+
+// execError wraps text/template execution error into ExecError, other errors are returned as is
+func execError(err error) error {
+	var te ttemplate.ExecError
+	if !errors.As(err, &te) {
+		return err
+	}
+	e := &ExecError{Name: te.Name, Err: err}
+	if m := reExecLocation.FindStringSubmatch(te.Error()); m != nil {
+		e.Name = m[1]
+		e.Line, _ = strconv.Atoi(m[2])
+	}
+	return e
+}
@@ -0,0 +1,68 @@
+package apitpl
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+var errFunc = errors.New("func error")
+
+func TestErrors(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "layouts/broken.html", "layout\n{{ fail }}", mtime)
+	writeTemplate(t, dir, "pages/page.html", `page`, mtime)
+	writeTemplate(t, dir, "pages/my/__id/fail.html", "page\n\n{{ fail }}", mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:    "layouts",
+		Pages:      "pages",
+		Ext:        ".html",
+		DefLayout:  "default",
+		Root:       dir,
+		HidePrefix: ".",
+	}
+	funcs := template.FuncMap{"fail": func() (string, error) { return "", errFunc }}
+	tfs, err := New(64).Funcs(funcs).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	page := samplemeta.NewMeta(200, "text/html")
+	assert.Nil(t, tfs.RenderContent("missing", template.FuncMap{}, page))
+	assert.True(t, errors.Is(page.Error(), ErrPageNotFound))
+	assert.EqualError(t, page.Error(), "page missing does not exist")
+
+	page = samplemeta.NewMeta(200, "text/html")
+	assert.Nil(t, tfs.RenderContent("my/:id/fail", template.FuncMap{}, page))
+	var ee *ExecError
+	require.True(t, errors.As(page.Error(), &ee))
+	assert.Equal(t, "my/:id/fail", ee.Name)
+	assert.Equal(t, 3, ee.Line)
+	assert.True(t, errors.Is(page.Error(), errFunc), "func error is available")
+
+	// Missing layout is detected by RenderContent
+	page = samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("unknown")
+	content := tfs.RenderContent("page", template.FuncMap{}, page)
+	require.NotNil(t, content)
+	assert.True(t, errors.Is(page.Error(), ErrLayoutNotFound))
+	assert.EqualError(t, page.Error(), "layout unknown does not exist")
+
+	// Layout execution error
+	page = samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("broken")
+	var b bytes.Buffer
+	err = tfs.Execute(&b, "page", template.FuncMap{}, page)
+	require.True(t, errors.As(err, &ee))
+	assert.Equal(t, "broken", ee.Name)
+	assert.Equal(t, 2, ee.Line)
+}
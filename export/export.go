@@ -0,0 +1,287 @@
+// Package export implements rendering of apitpl pages into static files.
+package export
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/apisite/apitpl"
+)
+
+// MetaData holds template metadata access methods used for export
+type MetaData interface {
+	apitpl.MetaData
+	Location() string // Returns redirect url
+	Status() int      // Response status
+}
+
+// TemplateService holds apitpl methods used for export
+type TemplateService interface {
+	PageNames(hide bool) []string
+	Render(w io.Writer, funcs template.FuncMap, data apitpl.MetaData, content *bytes.Buffer) (err error)
+	RenderContent(name string, funcs template.FuncMap, data apitpl.MetaData) *bytes.Buffer
+}
+
+// FuncBinder holds optional TemplateService method which returns request funcs of registered providers.
+// Funcs are bound (if TemplateService implements it) to GET request of exported page path
+type FuncBinder interface {
+	BindFuncs(ctx context.Context, r *http.Request) (template.FuncMap, error)
+}
+
+// ContentReleaser holds optional TemplateService method which takes back page content that is not rendered
+// (on redirect, for example), so its buffer may be reused
+type ContentReleaser interface {
+	ReleaseContent(content *bytes.Buffer)
+}
+
+// Report holds export results
+type Report struct {
+	Pages     []string          // Exported page paths
+	Redirects map[string]string // Page paths exported as redirect stubs with their locations
+	Skipped   []string          // Parameterized pages which were not exported
+	Errors    map[string]error  // Page paths (or parameterized pages with params if their path is unknown) which raised errors
+}
+
+// Exporter renders pages to static files
+type Exporter struct {
+	fs      TemplateService
+	newMeta func(page string) MetaData
+	funcs   template.FuncMap
+	params  ParamProvider
+}
+
+// ParamProvider returns route params sets for parameterized page (like "my/:id/hello"),
+// page is rendered once for every set
+type ParamProvider func(page string) ([]map[string]string, error)
+
+// redirectStub is written for pages which redirect
+var redirectStub = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0; url={{ . }}">
+<link rel="canonical" href="{{ . }}">
+</head>
+<body><a href="{{ . }}">{{ . }}</a></body>
+</html>
+`))
+
+// New creates Exporter for given templates and MetaData factory which is called for every page
+func New(fs TemplateService, newMeta func(page string) MetaData) *Exporter {
+	return &Exporter{fs: fs, newMeta: newMeta, funcs: template.FuncMap{}}
+}
+
+// Funcs sets funcs passed to every page. They override funcs bound by TemplateService providers,
+// so request funcs may be replaced with stubs for export
+func (e *Exporter) Funcs(funcs template.FuncMap) *Exporter {
+	for k, v := range funcs {
+		e.funcs[k] = v
+	}
+	return e
+}
+
+// Params sets provider of parameterized pages params
+func (e *Exporter) Params(provider ParamProvider) *Exporter {
+	e.params = provider
+	return e
+}
+
+// Export renders all of non-hidden pages into files under dir.
+// Parameterized pages are rendered for every params set returned by Params provider (if any),
+// otherwise they are skipped.
+// Page errors are collected in report, returned error means file write failure
+func (e *Exporter) Export(dir string) (*Report, error) {
+	report := &Report{Redirects: map[string]string{}, Errors: map[string]error{}}
+	// exported files with their page paths
+	files := map[string]string{}
+	for _, page := range e.fs.PageNames(true) {
+		if !IsParameterized(page) {
+			if err := e.exportPage(dir, page, page, nil, files, report); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if e.params == nil {
+			report.Skipped = append(report.Skipped, page)
+			continue
+		}
+		paramSets, err := e.params(page)
+		if err != nil {
+			report.Errors[page] = err
+			continue
+		}
+		if len(paramSets) == 0 {
+			report.Skipped = append(report.Skipped, page)
+			continue
+		}
+		for _, params := range paramSets {
+			path, err := PagePath(page, params)
+			if err != nil {
+				report.Errors[paramsKey(page, params)] = err
+				continue
+			}
+			if err = e.exportPage(dir, page, path, params, files, report); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// exportPage renders page with given params into file for path.
+// Page error is stored in report, as well as path which file was exported already (like "about" and "about/")
+func (e *Exporter) exportPage(dir, page, path string, params map[string]string, files map[string]string, report *Report) error {
+	file := FilePath(path)
+	if prev, ok := files[file]; ok {
+		report.Errors[path] = errors.Errorf("file %s is already exported for page %s", file, prev)
+		return nil
+	}
+	content, location, err := e.renderPage(page, path, params)
+	if err != nil {
+		report.Errors[path] = err
+		return nil
+	}
+	files[file] = path
+	if location != "" {
+		var buf bytes.Buffer
+		if err = redirectStub.Execute(&buf, location); err != nil {
+			return err
+		}
+		content = buf.Bytes()
+		report.Redirects[path] = location
+	} else {
+		report.Pages = append(report.Pages, path)
+	}
+	return writeFile(filepath.Join(dir, file), content)
+}
+
+// renderPage renders page content and layout for path.
+// Route params are available for page via param func.
+// Redirect location is returned if page redirects
+func (e *Exporter) renderPage(page, path string, params map[string]string) ([]byte, string, error) {
+	funcs, err := e.bindFuncs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	for k, v := range e.funcs {
+		funcs[k] = v
+	}
+	funcs["param"] = func(key string) string { return params[key] }
+	meta := e.newMeta(page)
+	content := e.fs.RenderContent(page, funcs, meta)
+	if meta.Status() == http.StatusMovedPermanently || meta.Status() == http.StatusFound {
+		e.release(content)
+		return nil, meta.Location(), nil
+	}
+	if err := meta.Error(); err != nil {
+		e.release(content)
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := e.fs.Render(&buf, funcs, meta, content); err != nil {
+		return nil, "", err
+	}
+	if err := meta.Error(); err != nil {
+		return nil, "", err
+	}
+	if meta.Status() >= http.StatusBadRequest {
+		return nil, "", errors.Errorf("page status %d", meta.Status())
+	}
+	return buf.Bytes(), "", nil
+}
+
+// release returns content to TemplateService if it supports it
+func (e *Exporter) release(content *bytes.Buffer) {
+	if cr, ok := e.fs.(ContentReleaser); ok && content != nil {
+		cr.ReleaseContent(content)
+	}
+}
+
+// bindFuncs returns funcs bound by TemplateService providers (if supported) for page path
+func (e *Exporter) bindFuncs(path string) (template.FuncMap, error) {
+	fb, ok := e.fs.(FuncBinder)
+	if !ok {
+		return make(template.FuncMap, len(e.funcs)+1), nil
+	}
+	r, err := http.NewRequest(http.MethodGet, "/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+	return fb.BindFuncs(r.Context(), r)
+}
+
+// IsParameterized returns true if page route has parameters (like "my/:id/hello")
+func IsParameterized(page string) bool {
+	for _, part := range strings.Split(page, "/") {
+		if strings.HasPrefix(part, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// PagePath returns page route (like "my/:id/hello") with params substituted (like "my/42/hello")
+func PagePath(page string, params map[string]string) (string, error) {
+	parts := strings.Split(page, "/")
+	for i, part := range parts {
+		if !strings.HasPrefix(part, ":") {
+			continue
+		}
+		key := part[1:]
+		v, ok := params[key]
+		if !ok {
+			return "", errors.Errorf("param %s is not set", key)
+		}
+		if v == "" || v == "." || v == ".." || strings.ContainsAny(v, `/\`) {
+			return "", errors.Errorf("param %s has invalid value %q", key, v)
+		}
+		parts[i] = v
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// paramsKey returns report key of parameterized page with params, like "my/:id/hello {id=../x}"
+func paramsKey(page string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + params[k]
+	}
+	return page + " {" + strings.Join(keys, ", ") + "}"
+}
+
+// FilePath returns file path for page.
+// Index pages (like "/" and "admin/") are written as "index.html" in their directory,
+// pages with extension (like "report.json") are written as is,
+// and other pages are written as "index.html" in directory named as page, so they are served by the same URL
+func FilePath(page string) string {
+	page = strings.TrimPrefix(page, "/")
+	switch {
+	case page == "" || strings.HasSuffix(page, "/"):
+		page += "index.html"
+	case strings.Contains(page[strings.LastIndex(page, "/")+1:], "."):
+	default:
+		page += "/index.html"
+	}
+	return filepath.FromSlash(page)
+}
+
+// writeFile writes data to file, creating its directory if needed
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, "create dir")
+	}
+	return errors.Wrap(os.WriteFile(path, data, 0o644), "write file")
+}
@@ -0,0 +1,215 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/ginapitpl/samplemeta"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+func mkExporter(t *testing.T) *Exporter {
+	cfg := lookupfs.Config{
+		Includes:   "inc",
+		Layouts:    "layout",
+		Pages:      "page",
+		Ext:        ".tmpl",
+		DefLayout:  "default",
+		Index:      "index",
+		Root:       "testdata",
+		HidePrefix: ".",
+		Variants:   "html,json",
+	}
+	funcs := template.FuncMap{
+		"HTML":  func(s string) template.HTML { return template.HTML(s) },
+		"site":  func() string { return "" },
+		"param": func(key string) string { return "" },
+	}
+	tfs, err := apitpl.New(64).Funcs(funcs).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+	newMeta := func(page string) MetaData { return samplemeta.NewMeta(200, "text/html; charset=utf-8") }
+	return New(tfs, newMeta).Funcs(template.FuncMap{"site": func() string { return "Example" }})
+}
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	report, err := mkExporter(t).Export(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/", "about", "docs/", "feed.json"}, report.Pages)
+	assert.Equal(t, map[string]string{"old": "/about?from=old&x=1"}, report.Redirects)
+	assert.Equal(t, []string{"my/:id/hello"}, report.Skipped)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors["err"].Error(), "Forbidden")
+
+	files := map[string]string{
+		"index.html":       "<title>Home - Example</title>\n<h1>Welcome</h1>\n",
+		"about/index.html": "<title>About - Example</title>\n<p>About us</p>\n",
+		"docs/index.html":  "<title>Docs - Example</title>\n<h1>Docs</h1>\n",
+		"feed.json":        "{\"site\": \"Example\"}\n",
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		assert.Equal(t, want, string(got), name)
+	}
+	stub, err := os.ReadFile(filepath.Join(dir, "old/index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(stub), `<meta http-equiv="refresh" content="0; url=/about?from=old&amp;x=1">`)
+	for _, name := range []string{"err/index.html", ".draft/index.html", "my"} {
+		assert.NoFileExists(t, filepath.Join(dir, name))
+	}
+}
+
+// releaser counts contents taken back by exporter
+type releaser struct {
+	*apitpl.TemplateService
+	released int
+}
+
+func (r *releaser) ReleaseContent(content *bytes.Buffer) {
+	r.released++
+	r.TemplateService.ReleaseContent(content)
+}
+
+func TestRenderPageRelease(t *testing.T) {
+	e := mkExporter(t)
+	fs := &releaser{TemplateService: e.fs.(*apitpl.TemplateService)}
+	e.fs = fs
+	// page content is rendered, but status set before says it redirects
+	e.newMeta = func(page string) MetaData { return samplemeta.NewMeta(http.StatusFound, "text/html; charset=utf-8") }
+	_, _, err := e.renderPage("about", "about", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fs.released, "redirect content is released")
+}
+
+func TestExportDuplicate(t *testing.T) {
+	root := t.TempDir()
+	for name, text := range map[string]string{
+		"layout/default.tmpl":    "{{ content }}",
+		"page/about.tmpl":        "about",
+		"page/about/index.tmpl":  "about index",
+		"page/about/people.tmpl": "people",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(text), 0o644))
+	}
+	cfg := lookupfs.Config{
+		Layouts:    "layout",
+		Pages:      "page",
+		Ext:        ".tmpl",
+		DefLayout:  "default",
+		Index:      "index",
+		Root:       root,
+		HidePrefix: ".",
+	}
+	tfs, err := apitpl.New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+	newMeta := func(page string) MetaData { return samplemeta.NewMeta(200, "text/html; charset=utf-8") }
+
+	dir := t.TempDir()
+	report, err := New(tfs, newMeta).Export(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"about", "about/people"}, report.Pages)
+	file := filepath.FromSlash("about/index.html")
+	assert.EqualError(t, report.Errors["about/"], "file "+file+" is already exported for page about")
+	got, err := os.ReadFile(filepath.Join(dir, file))
+	require.NoError(t, err)
+	assert.Equal(t, "about", string(got))
+}
+
+func TestExportParams(t *testing.T) {
+	dir := t.TempDir()
+	provider := func(page string) ([]map[string]string, error) {
+		assert.Equal(t, "my/:id/hello", page)
+		return []map[string]string{{"id": "1"}, {"id": "2"}, {"id": "../x"}, {"id": ""}}, nil
+	}
+	report, err := mkExporter(t).Params(provider).Export(dir)
+	require.NoError(t, err)
+	assert.Contains(t, report.Pages, "my/1/hello")
+	assert.Contains(t, report.Pages, "my/2/hello")
+	assert.Empty(t, report.Skipped)
+	require.Len(t, report.Errors, 3, "err page and both invalid params")
+	assert.EqualError(t, report.Errors["my/:id/hello {id=../x}"], `param id has invalid value "../x"`)
+	assert.EqualError(t, report.Errors["my/:id/hello {id=}"], `param id has invalid value ""`)
+
+	got, err := os.ReadFile(filepath.Join(dir, "my/2/hello/index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<title>Hello - Example</title>\n<p>Hello, 2!</p>\n", string(got))
+}
+
+func TestExportProviders(t *testing.T) {
+	cfg := lookupfs.Config{
+		Includes:   "inc",
+		Layouts:    "layout",
+		Pages:      "page",
+		Ext:        ".tmpl",
+		DefLayout:  "default",
+		Index:      "index",
+		Root:       "testdata",
+		HidePrefix: ".",
+		Variants:   "html,json",
+	}
+	provider := apitpl.RequestFuncs{
+		Prototype: template.FuncMap{"site": func() string { return "" }},
+		Binder: func(ctx context.Context, r *http.Request) template.FuncMap {
+			return template.FuncMap{"site": func() string { return "Site " + r.URL.Path }}
+		},
+	}
+	funcs := template.FuncMap{
+		"HTML":  func(s string) template.HTML { return template.HTML(s) },
+		"param": func(key string) string { return "" },
+	}
+	tfs, err := apitpl.New(64).Funcs(funcs).FuncProviders(provider).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+	newMeta := func(page string) MetaData { return samplemeta.NewMeta(200, "text/html; charset=utf-8") }
+
+	dir := t.TempDir()
+	report, err := New(tfs, newMeta).Export(dir)
+	require.NoError(t, err)
+	assert.Contains(t, report.Pages, "about")
+	got, err := os.ReadFile(filepath.Join(dir, "about/index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<title>About - Site /about</title>\n<p>About us</p>\n", string(got))
+
+	// Exporter funcs replace bound ones
+	dir = t.TempDir()
+	_, err = New(tfs, newMeta).Funcs(template.FuncMap{"site": func() string { return "Stub" }}).Export(dir)
+	require.NoError(t, err)
+	got, err = os.ReadFile(filepath.Join(dir, "about/index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, "<title>About - Stub</title>\n<p>About us</p>\n", string(got))
+}
+
+func TestPagePath(t *testing.T) {
+	path, err := PagePath("shop/:cat/:id", map[string]string{"cat": "books", "id": "42"})
+	require.NoError(t, err)
+	assert.Equal(t, "shop/books/42", path)
+	_, err = PagePath("shop/:cat/:id", map[string]string{"cat": "books"})
+	assert.EqualError(t, err, "param id is not set")
+	assert.Equal(t, "shop/:cat/:id {cat=books, id=42}", paramsKey("shop/:cat/:id", map[string]string{"id": "42", "cat": "books"}))
+}
+
+func TestFilePath(t *testing.T) {
+	tests := map[string]string{
+		"/":           "index.html",
+		"admin/":      "admin/index.html",
+		"about":       "about/index.html",
+		"docs/intro":  "docs/intro/index.html",
+		"feed.json":   "feed.json",
+		"v1.2/report": "v1.2/report/index.html",
+	}
+	for page, want := range tests {
+		assert.Equal(t, filepath.FromSlash(want), FilePath(page), page)
+	}
+}
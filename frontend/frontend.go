@@ -0,0 +1,358 @@
+// Package frontend implements router-neutral core of apitpl HTTP frontends.
+// Router packages (like ginapitpl) register Routes and call their handlers.
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gopkg.in/birkirb/loggers.v1"
+
+	"github.com/apisite/apitpl"
+)
+
+// maxMemory holds multipart form size stored in memory (like gin default)
+const maxMemory = 32 << 20
+
+// MetaData holds template metadata access methods
+type MetaData interface {
+	apitpl.MetaData
+	ContentType() string // Returns content type
+	Location() string    // Returns redirect url
+	Status() int         // Response status
+}
+
+// TemplateService allows to replace apitpl functionality with the other package
+type TemplateService interface {
+	PageNames(hide bool) []string
+	Render(w io.Writer, funcs template.FuncMap, data apitpl.MetaData, content *bytes.Buffer) (err error)
+	RenderContent(name string, funcs template.FuncMap, data apitpl.MetaData) *bytes.Buffer
+}
+
+// MethodService holds optional TemplateService method which returns HTTP methods allowed for page.
+// Pages are registered for GET method only if TemplateService does not implement it
+type MethodService interface {
+	PageMethods(name string) []string
+}
+
+// FuncBinder holds optional TemplateService method which returns request funcs of registered providers.
+// MetaFunc gets them (if TemplateService implements it) or empty funcmap otherwise
+type FuncBinder interface {
+	BindFuncs(ctx context.Context, r *http.Request) (template.FuncMap, error)
+}
+
+// ContextService holds optional TemplateService methods which render page with request context.
+// They are used (if TemplateService implements it) so rendering stops when request is cancelled
+type ContextService interface {
+	RenderContext(ctx context.Context, w io.Writer, funcs template.FuncMap, data apitpl.MetaData, content *bytes.Buffer) error
+	RenderContentContext(ctx context.Context, name string, funcs template.FuncMap, data apitpl.MetaData) *bytes.Buffer
+}
+
+// ErrorPageService holds optional TemplateService method which returns error page name for response status.
+// Pages with error status are rendered with their metadata and page error inline if TemplateService does not implement it
+type ErrorPageService interface {
+	ErrorPage(status int) (string, bool)
+}
+
+// ContentReleaser holds optional TemplateService method which takes back page content that is not rendered
+// (on redirect, for example), so its buffer may be reused
+type ContentReleaser interface {
+	ReleaseContent(content *bytes.Buffer)
+}
+
+// MetaFunc returns page metadata for request. Given funcs may be changed for this request
+type MetaFunc func(r *http.Request, funcs template.FuncMap) MetaData
+
+// PageHandler serves page with metadata returned by meta
+type PageHandler func(w http.ResponseWriter, r *http.Request, meta MetaFunc)
+
+// Route holds page route attributes
+type Route struct {
+	Method  string
+	Name    string // page name (or base name of page variants) with params like "my/:id/hello"
+	Handler PageHandler
+}
+
+// Frontend holds router-neutral page handling methods
+type Frontend struct {
+	fs  TemplateService
+	log loggers.Contextual
+}
+
+// New creates frontend object
+func New(log loggers.Contextual, fs TemplateService) *Frontend {
+	return &Frontend{fs: fs, log: log}
+}
+
+// Routes returns routes for all of visible pages.
+// Page variants are served by their own routes and by base name route which chooses variant by Accept header
+func (f Frontend) Routes() []Route {
+	pages := f.fs.PageNames(true)
+	groups := f.variantGroups(pages)
+	var routes []Route
+	for _, p := range pages {
+		if _, ok := groups[p]; ok {
+			// registered with its variants
+			continue
+		}
+		routes = f.appendRoutes(routes, p, p, f.Handler(p))
+	}
+	bases := make([]string, 0, len(groups))
+	for base := range groups {
+		bases = append(bases, base)
+	}
+	sort.Strings(bases)
+	for _, base := range bases {
+		routes = f.appendVariantRoutes(routes, base, groups[base])
+	}
+	return routes
+}
+
+// appendVariantRoutes appends routes of page variants group for all methods of its pages.
+// Method route serves variants which allow this method only
+func (f Frontend) appendVariantRoutes(routes []Route, name string, variants []pageVariant) []Route {
+	var methods []string
+	byMethod := map[string][]pageVariant{}
+	for _, v := range variants {
+		for _, method := range f.pageMethods(v.page) {
+			if _, ok := byMethod[method]; !ok {
+				methods = append(methods, method)
+			}
+			byMethod[method] = append(byMethod[method], v)
+		}
+	}
+	for _, method := range methods {
+		routes = append(routes, Route{Method: method, Name: name, Handler: f.variantsHandler(byMethod[method])})
+	}
+	return routes
+}
+
+// appendRoutes appends page handler routes for all of page methods
+func (f Frontend) appendRoutes(routes []Route, name, page string, handler PageHandler) []Route {
+	for _, method := range f.pageMethods(page) {
+		routes = append(routes, Route{Method: method, Name: name, Handler: handler})
+	}
+	return routes
+}
+
+// pageMethods returns HTTP methods allowed for page
+func (f Frontend) pageMethods(uri string) []string {
+	if ms, ok := f.fs.(MethodService); ok {
+		return ms.PageMethods(uri)
+	}
+	return []string{http.MethodGet}
+}
+
+// Path returns router path for page name with prefix. Path always starts with "/", even if prefix does not.
+// Page params (like ":id") are converted by param func
+func Path(prefix, name string, param func(name string) string) string {
+	parts := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") {
+			parts[i] = param(part[1:])
+		}
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix = "/" + prefix
+	}
+	return prefix + "/" + strings.Join(parts, "/")
+}
+
+// Handler returns handler of given page
+func (f Frontend) Handler(uri string) PageHandler {
+	return func(w http.ResponseWriter, r *http.Request, meta MetaFunc) {
+		f.Serve(w, r, uri, meta)
+	}
+}
+
+// Serve renders page for given uri with request.
+// Request form is parsed before meta call for methods other than GET and HEAD.
+// Response status, content type and redirect location are taken from page metadata
+func (f Frontend) Serve(w http.ResponseWriter, r *http.Request, uri string, meta MetaFunc) {
+	if err := parseForm(r); err != nil {
+		f.log.Error("Form parse error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	funcs, err := f.Funcs(r)
+	if err != nil {
+		f.log.Error("Funcs bind error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := f.Write(w, r, uri, funcs, meta(r, funcs)); err != nil {
+		f.log.Error("Render error", err)
+	}
+}
+
+// Write renders page content and layout into w.
+// Response status, content type and redirect location are taken from page metadata,
+// r is used for request context and redirect.
+// Page errors without error status are mapped by ErrorStatus.
+// If page sets error with status 400 or above, error page content (see ErrorPageService) is rendered instead of page one
+func (f Frontend) Write(w http.ResponseWriter, r *http.Request, uri string, funcs template.FuncMap, page MetaData) error {
+	content := f.renderContent(r, uri, funcs, page)
+	status := page.Status()
+	if status == http.StatusMovedPermanently || status == http.StatusFound {
+		f.release(content)
+		http.Redirect(w, r, page.Location(), status)
+		return nil
+	}
+	if err := page.Error(); err != nil {
+		if status < http.StatusBadRequest {
+			status = ErrorStatus(err, status)
+			if s, ok := page.(apitpl.StatusSetter); ok {
+				s.SetStatus(status)
+			}
+		}
+		if status >= http.StatusBadRequest {
+			content = f.errorContent(r, status, funcs, page, content)
+		}
+	}
+	w.Header().Set("Content-Type", page.ContentType())
+	w.WriteHeader(status)
+	if !bodyAllowed(status) {
+		f.release(content)
+		return nil
+	}
+	return f.render(r, w, funcs, page, content)
+}
+
+// ErrorStatus returns response status for page error: 404 for apitpl.ErrPageNotFound,
+// 503 for *apitpl.LimitError of time limit, 500 for other *apitpl.LimitError, apitpl.ErrLayoutNotFound
+// and *apitpl.ExecError. Given status is returned for other errors
+func ErrorStatus(err error, status int) int {
+	var ee *apitpl.ExecError
+	var le *apitpl.LimitError
+	switch {
+	case errors.Is(err, apitpl.ErrPageNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &le) && le.MaxSize == 0:
+		return http.StatusServiceUnavailable
+	case errors.Is(err, apitpl.ErrLayoutNotFound), errors.As(err, &ee), le != nil:
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// ServeError renders error page for given status (see ErrorPageService).
+// Plain status text is written if there is no such page or metadata does not implement SetStatus
+func (f Frontend) ServeError(w http.ResponseWriter, r *http.Request, status int, meta MetaFunc) {
+	name, ok := f.ErrorPage(status)
+	if !ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	funcs, err := f.Funcs(r)
+	if err != nil {
+		f.log.Error("Funcs bind error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	page := meta(r, funcs)
+	s, ok := page.(apitpl.StatusSetter)
+	if !ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	s.SetStatus(status)
+	if err := f.Write(w, r, name, funcs, page); err != nil {
+		f.log.Error("Render error", err)
+	}
+}
+
+// ErrorHandler returns handler which renders error page for given status
+func (f Frontend) ErrorHandler(status int) PageHandler {
+	return func(w http.ResponseWriter, r *http.Request, meta MetaFunc) {
+		f.ServeError(w, r, status, meta)
+	}
+}
+
+// ErrorPage returns error page name for status if TemplateService supports it
+func (f Frontend) ErrorPage(status int) (string, bool) {
+	if es, ok := f.fs.(ErrorPageService); ok {
+		return es.ErrorPage(status)
+	}
+	return "", false
+}
+
+// errorContent renders error page content for page error.
+// Error page may use page error, it is reset after rendering, so layout renders error page as usual content.
+// Given content is returned if there is no error page or it fails
+func (f Frontend) errorContent(r *http.Request, status int, funcs template.FuncMap, page MetaData, content *bytes.Buffer) *bytes.Buffer {
+	name, ok := f.ErrorPage(status)
+	if !ok {
+		return content
+	}
+	err := page.Error()
+	c := f.renderContent(r, name, funcs, page)
+	if c == nil {
+		f.log.Error("Error page render error", page.Error())
+		page.SetError(err)
+		return content
+	}
+	page.SetError(nil)
+	f.release(content)
+	return c
+}
+
+// release returns content to TemplateService if it supports it
+func (f Frontend) release(content *bytes.Buffer) {
+	if cr, ok := f.fs.(ContentReleaser); ok && content != nil {
+		cr.ReleaseContent(content)
+	}
+}
+
+// Funcs returns request funcs bound by TemplateService providers
+func (f Frontend) Funcs(r *http.Request) (template.FuncMap, error) {
+	if fb, ok := f.fs.(FuncBinder); ok {
+		return fb.BindFuncs(r.Context(), r)
+	}
+	return make(template.FuncMap), nil
+}
+
+// renderContent renders page content with request context if TemplateService supports it
+func (f Frontend) renderContent(r *http.Request, uri string, funcs template.FuncMap, page MetaData) *bytes.Buffer {
+	if cs, ok := f.fs.(ContextService); ok {
+		return cs.RenderContentContext(r.Context(), uri, funcs, page)
+	}
+	return f.fs.RenderContent(uri, funcs, page)
+}
+
+// render renders page layout with request context if TemplateService supports it
+func (f Frontend) render(r *http.Request, w io.Writer, funcs template.FuncMap, page MetaData, content *bytes.Buffer) error {
+	if cs, ok := f.fs.(ContextService); ok {
+		return cs.RenderContext(r.Context(), w, funcs, page, content)
+	}
+	return f.fs.Render(w, funcs, page, content)
+}
+
+// parseForm parses request body form, so its values are available via r.PostForm
+func parseForm(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return nil
+	}
+	if ctype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ctype == "multipart/form-data" {
+		return r.ParseMultipartForm(maxMemory)
+	}
+	return r.ParseForm()
+}
+
+// bodyAllowed returns false if response with given status has no body
+func bodyAllowed(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
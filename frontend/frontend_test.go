@@ -0,0 +1,246 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mapper "github.com/birkirb/loggers-mapper-logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/ginapitpl/samplemeta"
+)
+
+// pages implements TemplateService which renders page name
+type pages struct {
+	names   []string
+	methods map[string][]string
+}
+
+func (p pages) PageNames(hide bool) []string { return p.names }
+
+func (p pages) PageMethods(name string) []string {
+	if m, ok := p.methods[name]; ok {
+		return m
+	}
+	return []string{http.MethodGet}
+}
+
+func (p pages) PageVariant(name string) (string, string) {
+	if base, variant, ok := strings.Cut(name, "."); ok {
+		return base, variant
+	}
+	return name, ""
+}
+
+func (p pages) ErrorPage(status int) (string, bool) {
+	name := fmt.Sprintf("_error/%d", status)
+	for _, n := range p.names {
+		if n == name {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (p pages) RenderContent(name string, funcs template.FuncMap, data apitpl.MetaData) *bytes.Buffer {
+	return bytes.NewBufferString(name)
+}
+
+func (p pages) Render(w io.Writer, funcs template.FuncMap, data apitpl.MetaData, content *bytes.Buffer) error {
+	_, err := content.WriteTo(w)
+	return err
+}
+
+// releasePages implements ContentReleaser which stores released contents
+type releasePages struct {
+	pages
+	released *[]string
+}
+
+func (p releasePages) ReleaseContent(content *bytes.Buffer) {
+	*p.released = append(*p.released, content.String())
+}
+
+func TestRoutes(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	fs := pages{
+		names: []string{"/", "login", "my/:id/hello", "report", "report.json"},
+		methods: map[string][]string{
+			"login":       {http.MethodGet, http.MethodPost},
+			"report.json": {http.MethodGet, http.MethodPost},
+		},
+	}
+	f := New(mapper.NewLogger(l), fs)
+	routes := f.Routes()
+	var got []string
+	for _, rt := range routes {
+		got = append(got, rt.Method+" "+rt.Name)
+	}
+	assert.Equal(t, []string{"GET /", "GET login", "POST login", "GET my/:id/hello", "GET report.json", "POST report.json",
+		"GET report", "POST report"}, got)
+
+	meta := func(r *http.Request, funcs template.FuncMap) MetaData {
+		return samplemeta.NewMeta(http.StatusOK, "text/plain")
+	}
+	tests := []struct {
+		method string
+		accept string
+		want   string
+		status int
+	}{
+		{method: "GET", accept: "application/json", want: "report.json", status: http.StatusOK},
+		{method: "GET", accept: "", want: "report", status: http.StatusOK},
+		{method: "GET", accept: "image/png", status: http.StatusNotAcceptable},
+		// Only variants which allow method are served
+		{method: "POST", accept: "", want: "report.json", status: http.StatusOK},
+		{method: "POST", accept: "text/html", status: http.StatusNotAcceptable},
+	}
+	handlers := map[string]PageHandler{"GET": routes[len(routes)-2].Handler, "POST": routes[len(routes)-1].Handler}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "/report", strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", tt.accept)
+		resp := httptest.NewRecorder()
+		handlers[tt.method](resp, req, meta)
+		assert.Equal(t, tt.status, resp.Code, tt.accept)
+		assert.Equal(t, tt.want, resp.Body.String(), tt.accept)
+	}
+}
+
+func TestPath(t *testing.T) {
+	colon := func(name string) string { return ":" + name }
+	braces := func(name string) string { return "{" + name + "}" }
+	assert.Equal(t, "/", Path("", "/", colon))
+	assert.Equal(t, "/admin/", Path("", "admin/", colon))
+	assert.Equal(t, "/my/:id/hello", Path("", "my/:id/hello", colon))
+	assert.Equal(t, "/app/my/{id}/hello", Path("/app/", "my/:id/hello", braces))
+	assert.Equal(t, "/app/page", Path("app", "page", colon))
+	assert.Equal(t, "/app/", Path("app/", "/", colon))
+	assert.Equal(t, "/page", Path("/", "page", colon))
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"text/html", "application/json"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: "text/html"},
+		{accept: "application/json, text/html;q=0.9", want: "application/json"},
+		{accept: "application/*", want: "application/json"},
+		{accept: "*/*", want: "text/html"},
+		{accept: "image/png", want: ""},
+		{accept: "application/json;q=0, text/html", want: "text/html"},
+		{accept: "application/json;q=0.1, text/html", want: "text/html"},
+		{accept: "APPLICATION/JSON", want: "application/json"},
+		{accept: "text/html;q=0, */*", want: "application/json"},
+		{accept: "text/*;q=0.5, */*;q=0.8", want: "application/json"},
+		{accept: "application/json;q=0", want: ""},
+		{accept: "bad, application/json", want: "application/json"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiate(tt.accept, offers), tt.accept)
+	}
+}
+
+func TestParamFuncs(t *testing.T) {
+	params := map[string]string{"id": "777"}
+	ctx := WithParams(context.Background(), func(name string) string { return params[name] })
+	assert.Equal(t, "777", Param(ctx, "id"))
+	assert.Equal(t, "", Param(context.Background(), "id"))
+
+	r := httptest.NewRequest("GET", "/my/777/hello", nil)
+	funcs := ParamFuncs.Bind(ctx, r)
+	param, ok := funcs["param"].(func(string) string)
+	require.True(t, ok)
+	assert.Equal(t, "777", param("id"))
+}
+
+func TestServeError(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	f := New(mapper.NewLogger(l), pages{names: []string{"page", "_error/404"}})
+	meta := func(r *http.Request, funcs template.FuncMap) MetaData {
+		return samplemeta.NewMeta(http.StatusOK, "text/html")
+	}
+
+	tests := []struct {
+		status int
+		ctype  string
+		want   string
+	}{
+		{status: http.StatusNotFound, ctype: "text/html", want: "_error/404"},
+		{status: http.StatusMethodNotAllowed, ctype: "text/plain; charset=utf-8", want: "Method Not Allowed\n"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/missing", nil)
+		resp := httptest.NewRecorder()
+		f.ErrorHandler(tt.status)(resp, req, meta)
+		assert.Equal(t, tt.status, resp.Code, tt.status)
+		assert.Equal(t, tt.ctype, resp.Header().Get("Content-Type"), tt.status)
+		assert.Equal(t, tt.want, resp.Body.String(), tt.status)
+	}
+
+	// page error with error page status
+	page := samplemeta.NewMeta(http.StatusNotFound, "text/html")
+	page.SetError(fmt.Errorf("not found"))
+	req := httptest.NewRequest("GET", "/page", nil)
+	resp := httptest.NewRecorder()
+	require.NoError(t, f.Write(resp, req, "page", template.FuncMap{}, page))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, "_error/404", resp.Body.String())
+	assert.NoError(t, page.Error(), "error is reset for error page")
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		want   int
+	}{
+		{err: fmt.Errorf("page: %w", apitpl.ErrPageNotFound), status: http.StatusOK, want: http.StatusNotFound},
+		{err: fmt.Errorf("layout: %w", apitpl.ErrLayoutNotFound), status: http.StatusOK, want: http.StatusInternalServerError},
+		{err: &apitpl.ExecError{Name: "page", Err: fmt.Errorf("exec")}, status: http.StatusOK, want: http.StatusInternalServerError},
+		{err: &apitpl.LimitError{Name: "page", MaxSize: 10}, status: http.StatusOK, want: http.StatusInternalServerError},
+		{err: &apitpl.LimitError{Name: "page", Timeout: time.Second}, status: http.StatusOK, want: http.StatusServiceUnavailable},
+		{err: &apitpl.ExecError{Name: "page", Err: &apitpl.LimitError{Name: "page", Timeout: time.Second}},
+			status: http.StatusOK, want: http.StatusServiceUnavailable},
+		{err: fmt.Errorf("other"), status: http.StatusOK, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ErrorStatus(tt.err, tt.status), tt.err.Error())
+	}
+}
+
+func TestReleaseContent(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	var released []string
+	f := New(mapper.NewLogger(l), releasePages{pages: pages{names: []string{"page", "_error/404"}}, released: &released})
+
+	// page content is replaced by error page one
+	page := samplemeta.NewMeta(http.StatusNotFound, "text/html")
+	page.SetError(fmt.Errorf("not found"))
+	resp := httptest.NewRecorder()
+	require.NoError(t, f.Write(resp, httptest.NewRequest("GET", "/page", nil), "page", template.FuncMap{}, page))
+	assert.Equal(t, "_error/404", resp.Body.String())
+	assert.Equal(t, []string{"page"}, released)
+
+	// redirect
+	released = nil
+	page = samplemeta.NewMeta(http.StatusOK, "text/html")
+	page.RedirectFound("/other")
+	resp = httptest.NewRecorder()
+	require.NoError(t, f.Write(resp, httptest.NewRequest("GET", "/page", nil), "page", template.FuncMap{}, page))
+	assert.Equal(t, http.StatusFound, resp.Code)
+	assert.Equal(t, []string{"page"}, released)
+}
@@ -0,0 +1,45 @@
+package frontend
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+
+	"github.com/apisite/apitpl"
+)
+
+// ParamGetter returns route param value by name
+type ParamGetter func(name string) string
+
+// paramsKey holds context key of route params getter
+type paramsKey struct{}
+
+// WithParams returns ctx with route params getter.
+// Router packages set it to request context, so params are available via Param and ParamFuncs
+func WithParams(ctx context.Context, get ParamGetter) context.Context {
+	return context.WithValue(ctx, paramsKey{}, get)
+}
+
+// Param returns route param value stored in ctx by WithParams (or empty string if there is no such param)
+func Param(ctx context.Context, name string) string {
+	if get, ok := ctx.Value(paramsKey{}).(ParamGetter); ok {
+		return get(name)
+	}
+	return ""
+}
+
+// ParamFuncs provides template func param which returns route param value, e.g.
+//
+//	{{ param "id" }}
+//
+// It works with every router package, so templates do not depend on router in use
+var ParamFuncs apitpl.FuncProvider = apitpl.RequestFuncs{
+	Prototype: template.FuncMap{
+		"param": func(name string) string { return "" },
+	},
+	Binder: func(ctx context.Context, r *http.Request) template.FuncMap {
+		return template.FuncMap{
+			"param": func(name string) string { return Param(ctx, name) },
+		}
+	},
+}
@@ -0,0 +1,162 @@
+package frontend
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VariantService holds optional TemplateService method which splits page name into base name and variant
+// (like "report.json" into "report" and "json"). Page variants are not grouped if TemplateService does not implement it
+type VariantService interface {
+	PageVariant(name string) (base, variant string)
+}
+
+// mimeHTML holds media type of page without variant
+const mimeHTML = "text/html"
+
+// pageVariant holds page name with its media type
+type pageVariant struct {
+	page    string
+	variant string
+	ctype   string
+}
+
+// variantGroups groups pages by base name, so base name route serves all of its variants.
+// Only groups with variants are returned.
+// Page without variant goes first in group, then "html" variant and others sorted by name
+func (f Frontend) variantGroups(pages []string) map[string][]pageVariant {
+	vs, ok := f.fs.(VariantService)
+	if !ok {
+		return nil
+	}
+	all := map[string][]pageVariant{}
+	for _, p := range pages {
+		base, variant := vs.PageVariant(p)
+		all[base] = append(all[base], pageVariant{page: p, variant: variant, ctype: variantType(variant)})
+	}
+	groups := map[string][]pageVariant{}
+	for base, variants := range all {
+		if len(variants) == 1 && variants[0].variant == "" {
+			continue
+		}
+		sort.Slice(variants, func(i, j int) bool {
+			return variantOrder(variants[i].variant) < variantOrder(variants[j].variant)
+		})
+		groups[base] = variants
+	}
+	return groups
+}
+
+// variantOrder returns variant sort key
+func variantOrder(variant string) string {
+	switch variant {
+	case "":
+		return "0"
+	case "html":
+		return "1"
+	}
+	return "2" + variant
+}
+
+// variantType returns media type of page variant
+func variantType(variant string) string {
+	if variant == "" {
+		return mimeHTML
+	}
+	ctype, _, err := mime.ParseMediaType(mime.TypeByExtension("." + variant))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return ctype
+}
+
+// variantsHandler returns handler which chooses page variant by Accept header
+func (f Frontend) variantsHandler(variants []pageVariant) PageHandler {
+	offers := make([]string, len(variants))
+	handlers := make(map[string]PageHandler, len(variants))
+	for i, v := range variants {
+		offers[i] = v.ctype
+		if _, ok := handlers[v.ctype]; !ok {
+			handlers[v.ctype] = f.Handler(v.page)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request, meta MetaFunc) {
+		if h, ok := handlers[negotiate(r.Header.Get("Accept"), offers)]; ok {
+			h(w, r, meta)
+			return
+		}
+		w.WriteHeader(http.StatusNotAcceptable)
+	}
+}
+
+// mediaRange holds Accept header media range with its quality and header position
+type mediaRange struct {
+	media string
+	q     float64
+	index int
+}
+
+// parseAccept returns media ranges of Accept header. Invalid ranges are skipped
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for i, item := range strings.Split(accept, ",") {
+		media, params, err := mime.ParseMediaType(item)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{media: media, q: q, index: i})
+	}
+	return ranges
+}
+
+// negotiate returns the offer with the highest quality in Accept header.
+// Offer quality is set by the most specific media range matching it, and offers with zero quality are not acceptable.
+// Offers of the same quality are ordered by position of their media range in header, then by offers order.
+// First offer is returned if header is empty
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestRange := "", mediaRange{}
+	for _, offer := range offers {
+		media := strings.ToLower(offer)
+		match, specificity := mediaRange{}, -1
+		for _, mr := range ranges {
+			if sp := mediaMatch(mr.media, media); sp > specificity {
+				match, specificity = mr, sp
+			}
+		}
+		if specificity < 0 || match.q == 0 {
+			continue
+		}
+		if best == "" || match.q > bestRange.q || match.q == bestRange.q && match.index < bestRange.index {
+			best, bestRange = offer, match
+		}
+	}
+	return best
+}
+
+// mediaMatch returns specificity of media range (like "text/*") matching media type:
+// 2 for exact match, 1 for subtype wildcard, 0 for "*/*" and -1 if range does not match
+func mediaMatch(accepted, offer string) int {
+	switch {
+	case accepted == offer:
+		return 2
+	case accepted == "*/*":
+		return 0
+	}
+	if prefix, ok := strings.CutSuffix(accepted, "/*"); ok && strings.HasPrefix(offer, prefix+"/") {
+		return 1
+	}
+	return -1
+}
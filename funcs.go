@@ -0,0 +1,96 @@
+package apitpl
+
+import (
+	"fmt"
+	"html/template"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FuncMismatch holds per request func which signature differs from parse time one
+type FuncMismatch struct {
+	Name string
+	Want reflect.Type // parse time func type
+	Got  reflect.Type // per request func type
+}
+
+// FuncsError holds problems of per request funcs found by ValidateFuncs
+type FuncsError struct {
+	Missing  []string       // Proto funcs called by templates but not overridden
+	Mismatch []FuncMismatch // Funcs overridden with other signature
+}
+
+// Error returns error message
+func (e *FuncsError) Error() string {
+	var parts []string
+	if len(e.Missing) != 0 {
+		parts = append(parts, "not overridden: "+strings.Join(e.Missing, ", "))
+	}
+	for _, m := range e.Mismatch {
+		parts = append(parts, fmt.Sprintf("%s signature mismatch: %s expected, %s given", m.Name, m.Want, m.Got))
+	}
+	return "funcs " + strings.Join(parts, "; ")
+}
+
+// result returns nil if there are no problems, or error with sorted problems otherwise
+func (e *FuncsError) result() error {
+	if len(e.Missing) == 0 && len(e.Mismatch) == 0 {
+		return nil
+	}
+	sort.Strings(e.Missing)
+	sort.Slice(e.Mismatch, func(i, j int) bool { return e.Mismatch[i].Name < e.Mismatch[j].Name })
+	return e
+}
+
+// serviceFuncs are set by TemplateService itself, so their signature may differ
+var serviceFuncs = map[string]bool{"content": true, "block_content": true}
+
+// ProtoFuncs loads placeholder funcs which are used for parsing and must be overridden by funcs
+// passed to RenderContent (like request data accessors). See ValidateFuncs
+func (tfs *TemplateService) ProtoFuncs(funcMap template.FuncMap) *TemplateService {
+	for k, v := range funcMap {
+		tfs.protoFuncs[k] = v
+	}
+	return tfs.Funcs(funcMap)
+}
+
+// StrictFuncs enables ValidateFuncs call for every RenderContent call.
+// On validation failure page is not rendered and *FuncsError is set via MetaData.SetError
+func (tfs *TemplateService) StrictFuncs(flag bool) *TemplateService {
+	tfs.strictFuncs = flag
+	return tfs
+}
+
+// ValidateFuncs checks per request funcs against parse time ones.
+// It returns *FuncsError if any of proto funcs called by templates is not overridden
+// or any func has signature which differs from parse time one
+func (tfs *TemplateService) ValidateFuncs(funcs template.FuncMap) error {
+	s := tfs.snapshot()
+	e := &FuncsError{}
+	for k := range tfs.protoFuncs {
+		if _, ok := funcs[k]; !ok && s.usedFuncs[k] {
+			e.Missing = append(e.Missing, k)
+		}
+	}
+	for k, v := range funcs {
+		proto, ok := tfs.funcMap[k]
+		if !ok || serviceFuncs[k] {
+			continue
+		}
+		if want, got := reflect.TypeOf(proto), reflect.TypeOf(v); want != got {
+			e.Mismatch = append(e.Mismatch, FuncMismatch{Name: k, Want: want, Got: got})
+		}
+	}
+	return e.result()
+}
+
+// addFuncs adds funcs called by template to set
+func addFuncs(set map[string]bool, d *templateDeps) {
+	if d == nil {
+		return
+	}
+	for _, k := range d.funcs {
+		set[k] = true
+	}
+}
@@ -0,0 +1,75 @@
+package apitpl
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+func TestValidateFuncs(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `{{ user }} {{ param "id" }} {{ upper "x" }}`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	protos := template.FuncMap{
+		"user":   func() interface{} { return nil },
+		"param":  func(key string) string { return "" },
+		"unused": func() string { return "" },
+	}
+	tfs, err := New(64).
+		Funcs(template.FuncMap{"upper": func(s string) string { return s }}).
+		ProtoFuncs(protos).
+		LookupFS(lookupfs.New(cfg)).
+		Parse()
+	require.NoError(t, err)
+
+	funcs := template.FuncMap{
+		"user":  func() interface{} { return "Joe" },
+		"param": func(key string) string { return key },
+	}
+	assert.NoError(t, tfs.ValidateFuncs(funcs), "unused proto may be not overridden")
+
+	err = tfs.ValidateFuncs(template.FuncMap{
+		"param": func() string { return "" },
+		"other": func() string { return "" },
+	})
+	var fe *FuncsError
+	require.True(t, errors.As(err, &fe))
+	assert.Equal(t, []string{"user"}, fe.Missing)
+	assert.Equal(t, []FuncMismatch{{
+		Name: "param",
+		Want: reflect.TypeOf(func(string) string { return "" }),
+		Got:  reflect.TypeOf(func() string { return "" }),
+	}}, fe.Mismatch)
+	assert.EqualError(t, err, "funcs not overridden: user; param signature mismatch: func(string) string expected, func() string given")
+
+	// Runtime validation
+	tfs.StrictFuncs(true)
+	page := samplemeta.NewMeta(200, "text/html")
+	var b bytes.Buffer
+	require.NoError(t, tfs.Execute(&b, "page", template.FuncMap{}, page))
+	assert.True(t, errors.As(page.Error(), &fe))
+
+	page = samplemeta.NewMeta(200, "text/html")
+	b.Reset()
+	require.NoError(t, tfs.Execute(&b, "page", funcs, page))
+	require.NoError(t, page.Error())
+	assert.Equal(t, "[Joe id x]", b.String())
+}
@@ -2,45 +2,49 @@
 package ginapitpl
 
 import (
-	"bytes"
 	"html/template"
-	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gopkg.in/birkirb/loggers.v1"
 
-	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/frontend"
 )
 
 // EngineKey holds gin context key name for engine storage
 const EngineKey = "github.com/apisite/apitpl"
 
 // MetaData holds template metadata access methods
-type MetaData interface {
-	apitpl.MetaData
-	ContentType() string // Returns content type
-	Location() string    // Returns redirect url
-	Status() int         // Response status
-}
+type MetaData = frontend.MetaData
 
 // TemplateService allows to replace apitpl functionality with the other package
-type TemplateService interface {
-	PageNames(hide bool) []string
-	Render(w io.Writer, funcs template.FuncMap, data apitpl.MetaData, content *bytes.Buffer) (err error)
-	RenderContent(name string, funcs template.FuncMap, data apitpl.MetaData) *bytes.Buffer
-}
+type TemplateService = frontend.TemplateService
+
+// MethodService holds optional TemplateService method which returns HTTP methods allowed for page
+type MethodService = frontend.MethodService
+
+// FuncBinder holds optional TemplateService method which returns request funcs of registered providers
+type FuncBinder = frontend.FuncBinder
+
+// ContextService holds optional TemplateService methods which render page with request context
+type ContextService = frontend.ContextService
+
+// ErrorPageService holds optional TemplateService method which returns error page name for response status
+type ErrorPageService = frontend.ErrorPageService
+
+// VariantService holds optional TemplateService method which splits page name into base name and variant
+type VariantService = frontend.VariantService
 
 // Template holds template engine attributes
 type Template struct {
 	RequestHandler func(ctx *gin.Context, funcs template.FuncMap) MetaData
-	fs             TemplateService
+	core           *frontend.Frontend
 	log            loggers.Contextual
 }
 
 // New creates template object
 func New(log loggers.Contextual, fs TemplateService) *Template {
-	return &Template{fs: fs, log: log}
+	return &Template{core: frontend.New(log, fs), log: log}
 }
 
 // Middleware stores Engine in gin context
@@ -50,26 +54,48 @@ func (tmpl *Template) Middleware() gin.HandlerFunc {
 	}
 }
 
-// Route registers template routes into gin
+// Route registers template routes into gin.
+// Unknown routes are not handled here, see ErrorRoutes
 func (tmpl Template) Route(prefix string, r *gin.Engine) {
-	if prefix != "" {
-		prefix = prefix + "/"
-	}
-
 	// we need this before page registering
 	r.Use(tmpl.Middleware())
 
-	for _, p := range tmpl.fs.PageNames(true) {
-		r.GET(prefix+p, tmpl.handleHTML(p)) // TODO: map[content-type]Pages
+	for _, rt := range tmpl.core.Routes() {
+		r.Handle(rt.Method, frontend.Path(prefix, rt.Name, ginParam), tmpl.handle(rt.Handler))
+	}
+}
+
+// ErrorRoutes sets gin NoRoute and NoMethod handlers which render error pages (see apitpl.ErrorPage) if they exist.
+// It replaces handlers set by application before, so it should be called after Route instead of setting them.
+// Gin calls NoMethod handler only if r.HandleMethodNotAllowed is set, otherwise unknown method gets 404 response.
+// It is not set here because it changes responses of all routes of r
+func (tmpl Template) ErrorRoutes(r *gin.Engine) {
+	r.NoRoute(tmpl.handleError(http.StatusNotFound))
+	r.NoMethod(tmpl.handleError(http.StatusMethodNotAllowed))
+}
+
+// handleError returns gin handler which renders error page for status.
+// Gin default response is used if there is no such page
+func (tmpl Template) handleError(status int) gin.HandlerFunc {
+	h := tmpl.handle(tmpl.core.ErrorHandler(status))
+	return func(ctx *gin.Context) {
+		if _, ok := tmpl.core.ErrorPage(status); ok {
+			h(ctx)
+		}
 	}
 }
 
-// handleHTML returns gin page handler
-func (tmpl Template) handleHTML(uri string) gin.HandlerFunc {
+// ginParam returns gin route param
+func ginParam(name string) string {
+	return ":" + name
+}
+
+// handle returns gin handler which serves page by engine stored in gin context
+func (tmpl Template) handle(h frontend.PageHandler) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		if val, ok := ctx.Get(EngineKey); ok {
 			if t, ok := val.(*Template); ok {
-				t.HTML(ctx, uri)
+				t.serve(ctx, h)
 				return
 			}
 		}
@@ -77,32 +103,16 @@ func (tmpl Template) handleHTML(uri string) gin.HandlerFunc {
 	}
 }
 
-// HTML renders page for given uri with context
+// HTML renders page for given uri with context.
+// Request form is parsed before RequestHandler call for methods other than GET and HEAD
 func (tmpl Template) HTML(ctx *gin.Context, uri string) {
-	funcs := make(template.FuncMap)
-	page := (tmpl.RequestHandler)(ctx, funcs)
-	content := tmpl.fs.RenderContent(uri, funcs, page)
-	if page.Status() == http.StatusMovedPermanently || page.Status() == http.StatusFound {
-		ctx.Redirect(page.Status(), page.Location())
-		return
-	}
-	r := renderer{fs: tmpl.fs, funcMap: funcs, data: page, content: content}
-	ctx.Header("Content-Type", page.ContentType())
-	ctx.Render(page.Status(), r)
+	tmpl.serve(ctx, tmpl.core.Handler(uri))
 }
 
-// renderer holds per request rendering attributes
-type renderer struct {
-	fs      TemplateService
-	content *bytes.Buffer
-	funcMap template.FuncMap
-	data    MetaData
+// serve calls frontend handler with gin route params
+func (tmpl Template) serve(ctx *gin.Context, h frontend.PageHandler) {
+	ctx.Request = ctx.Request.WithContext(frontend.WithParams(ctx.Request.Context(), ctx.Param))
+	h(ctx.Writer, ctx.Request, func(r *http.Request, funcs template.FuncMap) MetaData {
+		return tmpl.RequestHandler(ctx, funcs)
+	})
 }
-
-// Render - render page and write it to w
-func (r renderer) Render(w http.ResponseWriter) error {
-	return r.fs.Render(w, r.funcMap, r.data, r.content)
-}
-
-// WriteContentType called when Status does not allow body
-func (r renderer) WriteContentType(w http.ResponseWriter) {}
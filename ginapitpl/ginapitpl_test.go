@@ -1,10 +1,12 @@
 package ginapitpl
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	mapper "github.com/birkirb/loggers-mapper-logrus"
@@ -13,6 +15,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/frontend"
 	"github.com/apisite/apitpl/lookupfs"
 
 	"github.com/apisite/apitpl/ginapitpl/samplemeta"
@@ -86,8 +89,9 @@ text/html; charset=utf-8
 </head>
 <body>
   <a href="/">Home</a><br />
-Escaped &lt;b&gt;error&lt;/b&gt; description
-    <footer>
+<h2>Server error 501</h2>
+<p>Escaped &lt;b&gt;error&lt;/b&gt; description</p>
+<footer>
 <hr>
 Host: <br />
 URL: /page?err=on<br />
@@ -111,6 +115,40 @@ URL: /err<br />
 </footer>
 </body>
 </html>
+`,
+		"/missing": `404
+text/html; charset=utf-8
+<html>
+<head>
+  <title>Page not found</title>
+</head>
+<body>
+  <a href="/">Home</a><br />
+<h2>Page /missing not found</h2>
+<footer>
+<hr>
+Host: <br />
+URL: /missing<br />
+</footer>
+</body>
+</html>
+`,
+		"/_error/404": `404
+text/html; charset=utf-8
+<html>
+<head>
+  <title>Page not found</title>
+</head>
+<body>
+  <a href="/">Home</a><br />
+<h2>Page /_error/404 not found</h2>
+<footer>
+<hr>
+Host: <br />
+URL: /_error/404<br />
+</footer>
+</body>
+</html>
 `,
 		"/redir": `302
 text/html; charset=utf-8
@@ -168,6 +206,15 @@ URL: /my/777/hello<br />
 }
 
 func mkRouter() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.Default()
+	tmpl := mkTemplate()
+	tmpl.Route("", r)
+	tmpl.ErrorRoutes(r)
+	return r
+}
+
+func mkTemplate() *Template {
 
 	// BufferPool size for rendered templates
 	const bufferSize int = 64
@@ -179,7 +226,8 @@ func mkRouter() *gin.Engine {
 	allFuncs["HTML"] = func(s string) template.HTML {
 		return template.HTML(s)
 	}
-	setProtoFuncs(allFuncs)
+	protoFuncs := make(template.FuncMap)
+	setProtoFuncs(protoFuncs)
 
 	cfg := lookupfs.Config{
 		Includes:   "inc",
@@ -190,9 +238,16 @@ func mkRouter() *gin.Engine {
 		Index:      "index",
 		Root:       "./testdata",
 		HidePrefix: ".",
+		Variants:   "html,json",
 	}
 	fs := lookupfs.New(cfg)
-	tfs, err := apitpl.New(bufferSize).Funcs(allFuncs).LookupFS(fs).Parse()
+	tfs, err := apitpl.New(bufferSize).
+		Funcs(allFuncs).
+		ProtoFuncs(protoFuncs).
+		FuncProviders(requestFuncs, frontend.ParamFuncs).
+		StrictFuncs(true).
+		LookupFS(fs).
+		Parse()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -202,23 +257,189 @@ func mkRouter() *gin.Engine {
 		page := samplemeta.NewMeta(http.StatusOK, "text/html; charset=utf-8")
 		return page
 	}
-
-	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
-	gintpl.Route("", r)
-	return r
+	return gintpl
 }
 
 // setProtoFuncs appends function templates and not related to request functions to funcs
 func setProtoFuncs(funcs template.FuncMap) {
 	funcs["data"] = func() interface{} { return nil }
-	funcs["request"] = func() interface{} { return nil }
-	funcs["param"] = func(key string) string { return "" }
 }
 
 // setRequestFuncs appends funcs which return real data inside request processing
 func setRequestFuncs(funcs template.FuncMap, ctx *gin.Context) {
 	funcs["data"] = func() interface{} { return samplemeta.Data }
-	funcs["request"] = func() interface{} { return ctx.Request }
-	funcs["param"] = func(key string) string { return ctx.Param(key) }
+}
+
+// requestFuncs provides funcs which need request only
+var requestFuncs = apitpl.RequestFuncs{
+	Prototype: template.FuncMap{
+		"request": func() interface{} { return nil },
+	},
+	Binder: func(ctx context.Context, r *http.Request) template.FuncMap {
+		return template.FuncMap{
+			"request": func() interface{} { return r },
+		}
+	},
+}
+
+func TestRenderMethods(t *testing.T) {
+	r := mkRouter()
+
+	tests := []struct {
+		method string
+		uri    string
+		body   string
+		status int
+		want   string
+	}{
+		{method: "GET", uri: "/login", status: http.StatusOK, want: `<form method="post"><input name="name"></form>`},
+		{method: "POST", uri: "/login", body: "name=Joe", status: http.StatusOK, want: "<h2>Hello, Joe!</h2>"},
+		{method: "POST", uri: "/login", body: "name=%zz", status: http.StatusBadRequest},
+		{method: "POST", uri: "/page", body: "name=Joe", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.uri, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		name := tt.method + " " + tt.uri
+		assert.Equal(t, tt.status, resp.Code, name)
+		if tt.want != "" {
+			assert.Contains(t, resp.Body.String(), "<title>Login</title>", name)
+			assert.Contains(t, resp.Body.String(), tt.want, name)
+		}
+	}
+}
+
+func TestNoMethod(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	tmpl := mkTemplate()
+	tmpl.Route("", r)
+	tmpl.ErrorRoutes(r)
+
+	req, _ := http.NewRequest("POST", "/page", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
+
+func TestNoRoute(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.NoRoute(func(ctx *gin.Context) { ctx.String(http.StatusNotFound, "app handler") })
+	tmpl := mkTemplate()
+	tmpl.Route("", r)
+
+	req, _ := http.NewRequest("GET", "/nope", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, "app handler", resp.Body.String(), "Route keeps application handler")
+
+	tmpl.ErrorRoutes(r)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), "<h2>Page /nope not found</h2>")
+}
+
+func TestRenderVariants(t *testing.T) {
+	r := mkRouter()
+
+	tests := []struct {
+		uri    string
+		accept string
+		status int
+		ctype  string
+		want   string
+	}{
+		{uri: "/page", accept: "text/html", status: http.StatusOK, ctype: "text/html; charset=utf-8", want: "<h3>Page content</h3>"},
+		{uri: "/page", accept: "application/json", status: http.StatusOK, ctype: "application/json", want: `"escaped": "<b>"`},
+		{uri: "/page", accept: "*/*", status: http.StatusOK, ctype: "text/html; charset=utf-8", want: "<h3>Page content</h3>"},
+		{uri: "/page", accept: "image/png", status: http.StatusNotAcceptable},
+		{uri: "/page.json", status: http.StatusOK, ctype: "application/json", want: `"escaped": "<b>"`},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.uri, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		name := tt.uri + " " + tt.accept
+		assert.Equal(t, tt.status, resp.Code, name)
+		if tt.want != "" {
+			assert.Equal(t, tt.ctype, resp.Header().Get("Content-Type"), name)
+			assert.Contains(t, resp.Body.String(), tt.want, name)
+		}
+	}
+}
+
+func TestRenderCancelled(t *testing.T) {
+	r := mkRouter()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/page", nil)
+	req.Header.Set("Accept", "text/html")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.NotContains(t, resp.Body.String(), "<h3>Page content</h3>")
+}
+
+func TestHTMLRender(t *testing.T) {
+	gintpl := mkTemplate()
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.HTMLRender = gintpl.HTMLRender()
+	r.GET("/custom/:id/*page", func(ctx *gin.Context) {
+		page, err := gintpl.Page(ctx)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		ctx.HTML(http.StatusAccepted, strings.TrimPrefix(ctx.Param("page"), "/"), page)
+	})
+	r.GET("/raw", func(ctx *gin.Context) {
+		ctx.HTML(http.StatusOK, "page", gin.H{})
+	})
+
+	tests := []struct {
+		uri      string
+		status   int
+		location string
+		want     string
+	}{
+		{uri: "/custom/777/my/:id/hello", status: http.StatusAccepted, want: "<h2>Hello, 777!</h2>"},
+		{uri: "/custom/1/page?wide=1", status: http.StatusAccepted, want: "<title>Test page</title>"},
+		{uri: "/custom/1/err", status: http.StatusForbidden, want: "Error description"},
+		{uri: "/custom/1/redir", status: http.StatusFound, location: "/page"},
+		{uri: "/custom/1/missing", status: http.StatusNotFound, want: "<h2>Page /custom/1/missing not found</h2>"},
+		{uri: "/custom/1/broken", status: http.StatusInternalServerError, want: "<h2>Server error 500</h2>\n<p>template: broken:2:3: executing"},
+		{uri: "/raw", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.uri, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, tt.status, resp.Code, tt.uri)
+		if tt.location != "" {
+			assert.Equal(t, tt.location, resp.Header().Get("Location"), tt.uri)
+			continue
+		}
+		if tt.want == "" {
+			assert.Empty(t, resp.Body.String(), tt.uri)
+			continue
+		}
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header().Get("Content-Type"), tt.uri)
+		assert.Contains(t, resp.Body.String(), tt.want, tt.uri)
+	}
+	// wide layout has no menu
+	req, _ := http.NewRequest("GET", "/custom/1/page?wide=1", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.NotContains(t, resp.Body.String(), `<a href="/">Home</a>`)
 }
@@ -0,0 +1,83 @@
+package ginapitpl
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/pkg/errors"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/frontend"
+)
+
+// Page holds page metadata and funcs for rendering by gin handler via ctx.HTML
+type Page struct {
+	Meta    MetaData
+	Funcs   template.FuncMap
+	request *http.Request
+}
+
+// Page returns page metadata and funcs prepared like for routed pages (by FuncBinder and RequestHandler),
+// so gin handler may add its own data and render page via ctx.HTML
+func (tmpl Template) Page(ctx *gin.Context) (*Page, error) {
+	ctx.Request = ctx.Request.WithContext(frontend.WithParams(ctx.Request.Context(), ctx.Param))
+	funcs, err := tmpl.core.Funcs(ctx.Request)
+	if err != nil {
+		return nil, err
+	}
+	return &Page{Meta: tmpl.RequestHandler(ctx, funcs), Funcs: funcs, request: ctx.Request}, nil
+}
+
+// HTMLRender returns gin HTML renderer which renders apitpl pages, so gin handlers may use them like
+//
+//	r.HTMLRender = gintpl.HTMLRender()
+//	...
+//	page, err := gintpl.Page(ctx)
+//	page.Funcs["order"] = func() *Order { return order }
+//	ctx.HTML(http.StatusOK, "orders/show", page)
+//
+// ctx.HTML data must be *Page. Its status is set to page metadata (if it implements SetStatus)
+// before page rendering, so template may change it as well as layout
+func (tmpl *Template) HTMLRender() render.HTMLRender {
+	return htmlRender{core: tmpl.core}
+}
+
+// htmlRender implements gin render.HTMLRender
+type htmlRender struct {
+	core *frontend.Frontend
+}
+
+// Instance returns page renderer
+func (hr htmlRender) Instance(name string, data any) render.Render {
+	return pageRender{core: hr.core, name: name, data: data}
+}
+
+// pageRender holds ctx.HTML attributes
+type pageRender struct {
+	core *frontend.Frontend
+	name string
+	data any
+}
+
+// Render renders page content and layout
+func (pr pageRender) Render(w http.ResponseWriter) error {
+	page, ok := pr.data.(*Page)
+	if !ok {
+		return errors.Errorf("page %s: data must be *ginapitpl.Page, got %T", pr.name, pr.data)
+	}
+	if sw, ok := w.(interface{ Status() int }); ok {
+		if s, ok := page.Meta.(apitpl.StatusSetter); ok && sw.Status() != 0 {
+			s.SetStatus(sw.Status())
+		}
+	}
+	return pr.core.Write(w, page.request, pr.name, page.Funcs, page.Meta)
+}
+
+// WriteContentType called when status does not allow body
+func (pr pageRender) WriteContentType(w http.ResponseWriter) {
+	if page, ok := pr.data.(*Page); ok {
+		w.Header().Set("Content-Type", page.Meta.ContentType())
+	}
+}
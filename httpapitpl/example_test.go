@@ -0,0 +1,69 @@
+package httpapitpl_test
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	mapper "github.com/birkirb/loggers-mapper-logrus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/frontend"
+	"github.com/apisite/apitpl/ginapitpl/samplemeta"
+	"github.com/apisite/apitpl/httpapitpl"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+func Example() {
+
+	// BufferPool size for rendered templates
+	const bufferSize int = 64
+
+	cfg := lookupfs.Config{
+		Layouts:    "layout",
+		Pages:      "page",
+		Ext:        ".tmpl",
+		DefLayout:  "default",
+		Index:      "index",
+		Root:       "./testdata",
+		HidePrefix: ".",
+	}
+	funcs := template.FuncMap{
+		"HTML":    func(s string) template.HTML { return template.HTML(s) },
+		"request": func() *http.Request { return nil },
+	}
+	tfs, err := apitpl.New(bufferSize).
+		Funcs(funcs).
+		FuncProviders(frontend.ParamFuncs).
+		LookupFS(lookupfs.New(cfg)).
+		Parse()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmpl := httpapitpl.New(mapper.NewLogger(logrus.New()), tfs)
+	tmpl.RequestHandler = func(r *http.Request, funcs template.FuncMap) httpapitpl.MetaData {
+		funcs["request"] = func() *http.Request { return r }
+		return samplemeta.NewMeta(http.StatusOK, "text/html; charset=utf-8")
+	}
+
+	mux := http.NewServeMux()
+	tmpl.Route("", mux)
+
+	req, _ := http.NewRequest("GET", "/my/777/hello", nil)
+	resp := httptest.NewRecorder()
+
+	mux.ServeHTTP(resp, req)
+
+	fmt.Println(resp.Code)
+	fmt.Println(resp.Header().Get("Content-Type"))
+	fmt.Println(resp.Body.String())
+
+	// Output:
+	// 200
+	// text/html; charset=utf-8
+	// <title>Default title</title>
+	// <h2>Hello, 777!</h2>
+}
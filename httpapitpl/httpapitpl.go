@@ -0,0 +1,73 @@
+// Package httpapitpl implements a net/http frontend for apitpl.
+package httpapitpl
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"gopkg.in/birkirb/loggers.v1"
+
+	"github.com/apisite/apitpl/frontend"
+)
+
+// MetaData holds template metadata access methods
+type MetaData = frontend.MetaData
+
+// TemplateService allows to replace apitpl functionality with the other package
+type TemplateService = frontend.TemplateService
+
+// Template holds template engine attributes
+type Template struct {
+	// RequestHandler returns page metadata. Page params (like "id" for "my/:id/hello" page) are available via r.PathValue
+	RequestHandler func(r *http.Request, funcs template.FuncMap) MetaData
+	core           *frontend.Frontend
+}
+
+// New creates template object
+func New(log loggers.Contextual, fs TemplateService) *Template {
+	return &Template{core: frontend.New(log, fs)}
+}
+
+// Route registers template routes into mux.
+// Page params (like ":id" for "__id" directory) are registered as wildcards ("{id}")
+func (tmpl Template) Route(prefix string, mux *http.ServeMux) {
+	for _, rt := range tmpl.core.Routes() {
+		mux.HandleFunc(rt.Method+" "+Pattern(prefix, rt.Name), tmpl.handle(rt.Handler))
+	}
+}
+
+// Pattern returns ServeMux path pattern for page name.
+// Page params are converted into wildcards and index pages (with name ending by slash) match their path only
+func Pattern(prefix, name string) string {
+	path := frontend.Path(prefix, name, func(name string) string { return "{" + name + "}" })
+	if strings.HasSuffix(path, "/") {
+		path += "{$}"
+	}
+	return path
+}
+
+// Handler returns page handler
+func (tmpl Template) Handler(uri string) http.HandlerFunc {
+	return tmpl.handle(tmpl.core.Handler(uri))
+}
+
+// HTML renders page for given uri with request.
+// Request form is parsed before RequestHandler call for methods other than GET and HEAD
+func (tmpl Template) HTML(w http.ResponseWriter, r *http.Request, uri string) {
+	tmpl.Handler(uri)(w, r)
+}
+
+// ErrorHandler returns handler which renders error page for given status (e.g. for "GET /" pattern, so unknown URLs get 404 page).
+// Plain status text is written if there is no such page
+func (tmpl Template) ErrorHandler(status int) http.HandlerFunc {
+	return tmpl.handle(tmpl.core.ErrorHandler(status))
+}
+
+// handle returns http handler which passes route params to frontend handler
+func (tmpl Template) handle(h frontend.PageHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(frontend.WithParams(r.Context(), r.PathValue))
+		h(w, r, tmpl.RequestHandler)
+	}
+}
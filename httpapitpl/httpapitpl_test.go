@@ -0,0 +1,135 @@
+package httpapitpl
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mapper "github.com/birkirb/loggers-mapper-logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/frontend"
+	"github.com/apisite/apitpl/ginapitpl/samplemeta"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		prefix string
+		name   string
+		want   string
+	}{
+		{name: "/", want: "/{$}"},
+		{name: "page", want: "/page"},
+		{name: "admin/", want: "/admin/{$}"},
+		{name: "my/:id/hello", want: "/my/{id}/hello"},
+		{prefix: "/app/", name: "page.json", want: "/app/page.json"},
+		{prefix: "app", name: "page", want: "/app/page"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Pattern(tt.prefix, tt.name), tt.name)
+	}
+}
+
+func TestRender(t *testing.T) {
+	mux := mkMux()
+
+	tests := []struct {
+		method   string
+		uri      string
+		accept   string
+		body     string
+		status   int
+		ctype    string
+		location string
+		want     string
+	}{
+		{method: "GET", uri: "/", status: http.StatusOK, ctype: "text/html; charset=utf-8", want: "<title>index page</title>\n<h2>Index</h2>\n"},
+		{method: "GET", uri: "/admin/", status: http.StatusOK, want: "<h2>admin index page</h2>"},
+		{method: "GET", uri: "/admin/x", status: http.StatusNotFound, want: "<title>Not found</title>\n<h2>Page /admin/x not found</h2>"},
+		{method: "GET", uri: "/my/777/hello", status: http.StatusOK, want: "<h2>Hello, 777!</h2>"},
+		{method: "GET", uri: "/redir", status: http.StatusFound, location: "/page"},
+		{method: "GET", uri: "/err", status: http.StatusForbidden, want: "<title>Default title</title>\nError description"},
+		{method: "GET", uri: "/login", status: http.StatusOK, want: `<form method="post"><input name="name"></form>`},
+		{method: "POST", uri: "/login", body: "name=Joe", status: http.StatusOK, want: "<h2>Hello, Joe!</h2>"},
+		{method: "POST", uri: "/login", body: "name=%zz", status: http.StatusBadRequest},
+		{method: "POST", uri: "/page", body: "name=Joe", status: http.StatusMethodNotAllowed},
+		{method: "GET", uri: "/page", accept: "text/html", status: http.StatusOK, ctype: "text/html; charset=utf-8", want: "<h3>Page content</h3>"},
+		{method: "GET", uri: "/page", accept: "application/json", status: http.StatusOK, ctype: "application/json", want: `"escaped": "<b>"`},
+		{method: "GET", uri: "/page", accept: "image/png", status: http.StatusNotAcceptable},
+		{method: "GET", uri: "/page.json", status: http.StatusOK, ctype: "application/json", want: `"escaped": "<b>"`},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.uri, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, req)
+		name := tt.method + " " + tt.uri + " " + tt.accept
+		assert.Equal(t, tt.status, resp.Code, name)
+		if tt.ctype != "" {
+			assert.Equal(t, tt.ctype, resp.Header().Get("Content-Type"), name)
+		}
+		if tt.location != "" {
+			assert.Equal(t, tt.location, resp.Header().Get("Location"), name)
+		}
+		if tt.want != "" {
+			assert.Contains(t, resp.Body.String(), tt.want, name)
+		}
+	}
+}
+
+func mkMux() *http.ServeMux {
+
+	// BufferPool size for rendered templates
+	const bufferSize int = 64
+
+	l, _ := test.NewNullLogger()
+	log := mapper.NewLogger(l)
+
+	cfg := lookupfs.Config{
+		Layouts:    "layout",
+		Pages:      "page",
+		Ext:        ".tmpl",
+		DefLayout:  "default",
+		Index:      "index",
+		Root:       "./testdata",
+		HidePrefix: ".",
+		Variants:   "html,json",
+	}
+	tfs, err := apitpl.New(bufferSize).
+		Funcs(template.FuncMap{"HTML": func(s string) template.HTML { return template.HTML(s) }}).
+		FuncProviders(requestFuncs, frontend.ParamFuncs).
+		LookupFS(lookupfs.New(cfg)).
+		Parse()
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmpl := New(log, tfs)
+	tmpl.RequestHandler = func(r *http.Request, funcs template.FuncMap) MetaData {
+		return samplemeta.NewMeta(http.StatusOK, "text/html; charset=utf-8")
+	}
+	mux := http.NewServeMux()
+	tmpl.Route("", mux)
+	mux.Handle("GET /", tmpl.ErrorHandler(http.StatusNotFound))
+	return mux
+}
+
+// requestFuncs provides request func
+var requestFuncs = apitpl.RequestFuncs{
+	Prototype: template.FuncMap{
+		"request": func() *http.Request { return nil },
+	},
+	Binder: func(ctx context.Context, r *http.Request) template.FuncMap {
+		return template.FuncMap{
+			"request": func() *http.Request { return r },
+		}
+	},
+}
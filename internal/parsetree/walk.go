@@ -0,0 +1,58 @@
+// Package parsetree implements text/template parse tree traversal.
+package parsetree
+
+import (
+	"text/template/parse"
+)
+
+// Walk calls fn for node and all of its children
+func Walk(node parse.Node, fn func(parse.Node)) {
+	if node == nil || isNil(node) {
+		return
+	}
+	fn(node)
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, item := range n.Nodes {
+			Walk(item, fn)
+		}
+	case *parse.ActionNode:
+		Walk(n.Pipe, fn)
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			Walk(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			Walk(arg, fn)
+		}
+	case *parse.ChainNode:
+		Walk(n.Node, fn)
+	case *parse.TemplateNode:
+		Walk(n.Pipe, fn)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	}
+}
+
+// walkBranch walks if, range & with node children
+func walkBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	Walk(n.Pipe, fn)
+	Walk(n.List, fn)
+	Walk(n.ElseList, fn)
+}
+
+// isNil returns true if node holds typed nil pointer
+func isNil(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		return n == nil
+	case *parse.PipeNode:
+		return n == nil
+	}
+	return false
+}
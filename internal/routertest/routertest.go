@@ -0,0 +1,82 @@
+// Package routertest implements router adapters tests with shared templates and requests.
+package routertest
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/frontend"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+// Prefix is the path prefix which adapters route templates under
+const Prefix = "/app"
+
+//go:embed all:testdata
+var embedFS embed.FS
+
+// Case holds request and expected response
+type Case struct {
+	Method   string
+	URI      string
+	Status   int
+	Location string
+	Want     string
+}
+
+// Cases holds requests to templates routed under Prefix
+var Cases = []Case{
+	{Method: "GET", URI: "/app/", Status: http.StatusOK, Want: "[index]"},
+	{Method: "GET", URI: "/app/my/777/hello", Status: http.StatusOK, Want: "[Hello, 777!]"},
+	{Method: "GET", URI: "/app/redir", Status: http.StatusFound, Location: "/page"},
+	{Method: "POST", URI: "/app/page", Status: http.StatusAccepted, Want: "[page]"},
+	{Method: "DELETE", URI: "/app/page", Status: http.StatusMethodNotAllowed},
+	{Method: "GET", URI: "/app/missing", Status: http.StatusNotFound},
+}
+
+// Service returns parsed test templates with route params funcs
+func Service(t *testing.T) *apitpl.TemplateService {
+	dirFS, err := fs.Sub(embedFS, "testdata")
+	require.NoError(t, err)
+	cfg := lookupfs.Config{
+		Layouts:    "layout",
+		Pages:      "page",
+		Ext:        ".tmpl",
+		DefLayout:  "default",
+		Index:      "index",
+		HidePrefix: ".",
+	}
+	tfs, err := apitpl.New(64).
+		FuncProviders(frontend.ParamFuncs).
+		LookupFS(lookupfs.New(cfg).FileSystem(dirFS)).
+		Parse()
+	require.NoError(t, err)
+	return tfs
+}
+
+// Run serves Cases by h and checks responses
+func Run(t *testing.T, h http.Handler) {
+	for _, tt := range Cases {
+		req, _ := http.NewRequest(tt.Method, tt.URI, strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		name := tt.Method + " " + tt.URI
+		assert.Equal(t, tt.Status, resp.Code, name)
+		if tt.Location != "" {
+			assert.Equal(t, tt.Location, resp.Header().Get("Location"), name)
+		}
+		if tt.Want != "" {
+			assert.Equal(t, "text/html; charset=utf-8", resp.Header().Get("Content-Type"), name)
+			assert.Equal(t, tt.Want, resp.Body.String(), name)
+		}
+	}
+}
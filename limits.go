@@ -0,0 +1,121 @@
+package apitpl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+// LimitError is returned (or set via MetaData.SetError for page content) when template execution
+// exceeds time or output size limit
+type LimitError struct {
+	Name    string        // template name
+	Timeout time.Duration // exceeded time limit (if any)
+	MaxSize int           // exceeded size limit (if any)
+}
+
+// Error returns error message
+func (e *LimitError) Error() string {
+	if e.MaxSize > 0 {
+		return fmt.Sprintf("template %s: output exceeds %d bytes", e.Name, e.MaxSize)
+	}
+	return fmt.Sprintf("template %s: execution exceeds %s", e.Name, e.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded for time limit error
+func (e *LimitError) Unwrap() error {
+	if e.MaxSize > 0 {
+		return nil
+	}
+	return context.DeadlineExceeded
+}
+
+// Timeout sets execution time limit for page content and layout (each one).
+// Template is stopped on first output after timeout, and its result is rejected if it finishes after timeout
+// without output. Template funcs are not interrupted, but page and layout funcs get context with deadline
+// via context func, so long running funcs may stop on it. Page sections are rendered with page output size limit
+// until layout deadline. Page front matter may override limit for page content.
+func (tfs *TemplateService) Timeout(d time.Duration) *TemplateService {
+	tfs.limits.timeout = d
+	return tfs
+}
+
+// MaxSize sets output size limit (in bytes) for page content and layout.
+// Layout limit does not count page content size. Page front matter may override it for page content.
+func (tfs *TemplateService) MaxSize(size int) *TemplateService {
+	tfs.limits.maxSize = size
+	return tfs
+}
+
+// limits holds template execution limits, zero means no limit
+type limits struct {
+	timeout time.Duration
+	maxSize int
+}
+
+// withMeta returns limits overridden by front matter (if any)
+func (l limits) withMeta(fm *lookupfs.FrontMatter) limits {
+	if fm == nil {
+		return l
+	}
+	if d := fm.TimeoutDuration(); d != 0 {
+		l.timeout = d
+	}
+	if fm.MaxSize != 0 {
+		l.maxSize = fm.MaxSize
+	}
+	return l
+}
+
+// context returns ctx with limit deadline (if any)
+func (l limits) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, l.timeout)
+}
+
+// writer returns w with output size limit (if any). Limit is increased by extra bytes
+func (l limits) writer(w io.Writer, extra int) io.Writer {
+	if l.maxSize <= 0 {
+		return w
+	}
+	return &limitWriter{w: w, left: l.maxSize + extra}
+}
+
+// error converts execution error caused by limits (with parent context not done) into LimitError
+func (l limits) error(parent context.Context, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, errSizeExceeded) {
+		return &LimitError{Name: name, MaxSize: l.maxSize}
+	}
+	if l.timeout > 0 && errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+		return &LimitError{Name: name, Timeout: l.timeout}
+	}
+	return err
+}
+
+// errSizeExceeded is returned by limitWriter when limit is reached
+var errSizeExceeded = errors.New("output size exceeded")
+
+// limitWriter fails writing after given number of bytes
+type limitWriter struct {
+	w    io.Writer
+	left int
+}
+
+// Write writes p if it fits the limit
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > lw.left {
+		return 0, errSizeExceeded
+	}
+	lw.left -= len(p)
+	return lw.w.Write(p)
+}
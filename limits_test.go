@@ -0,0 +1,127 @@
+package apitpl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+func TestLimits(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `{{ with .Error }}error: {{ . }}{{ else }}[{{ content }}]{{ end }}`, mtime)
+	writeTemplate(t, dir, "layouts/wide.html", `{{ range seq }}==========={{ end }}`, mtime)
+	writeTemplate(t, dir, "pages/big.html", `{{ range seq }}0123456789{{ end }}`, mtime)
+	writeTemplate(t, dir, "pages/bigger.html", "---\nmax_size: 200\n---\n{{ range seq }}0123456789{{ end }}", mtime)
+	writeTemplate(t, dir, "pages/slow.html", `{{ sleep }}slow`, mtime)
+	writeTemplate(t, dir, "pages/quiet.html", `{{ sleep }}`, mtime)
+	writeTemplate(t, dir, "pages/deadline.html", `{{ wait context }}`, mtime)
+	writeTemplate(t, dir, "pages/slower.html", "---\ntimeout: 1s\n---\n{{ sleep }}slow", mtime)
+	writeTemplate(t, dir, "pages/small.html", `small`, mtime)
+	writeTemplate(t, dir, "layouts/sections.html", `{{ block_content "big" }}{{ content }}`, mtime)
+	writeTemplate(t, dir, "layouts/waiting.html", `{{ wait context }}{{ content }}`, mtime)
+	writeTemplate(t, dir, "pages/sectioned.html", `{{ define "big" }}{{ range seq }}0123456789{{ end }}{{ end }}small`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	funcs := template.FuncMap{
+		"seq":   func() []int { return make([]int, 10) },
+		"sleep": func() string { time.Sleep(50 * time.Millisecond); return "" },
+		"wait": func(ctx context.Context) (string, error) {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(time.Second):
+				return "done", nil
+			}
+		},
+	}
+	tfs, err := New(64).Funcs(funcs).MaxSize(90).Timeout(20 * time.Millisecond).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		err  *LimitError
+		want string
+	}{
+		{name: "big", err: &LimitError{Name: "big", MaxSize: 90},
+			want: "error: template big: output exceeds 90 bytes"},
+		{name: "bigger", want: "[" + strings.Repeat("0123456789", 10) + "]"},
+		{name: "slow", err: &LimitError{Name: "slow", Timeout: 20 * time.Millisecond},
+			want: "error: template slow: execution exceeds 20ms"},
+		{name: "slower", want: "[slow]"},
+		// no output after timeout
+		{name: "quiet", err: &LimitError{Name: "quiet", Timeout: 20 * time.Millisecond},
+			want: "error: template quiet: execution exceeds 20ms"},
+		// func stops on deadline
+		{name: "deadline", err: &LimitError{Name: "deadline", Timeout: 20 * time.Millisecond},
+			want: "error: template deadline: execution exceeds 20ms"},
+	}
+	for _, tt := range tests {
+		page := samplemeta.NewMeta(200, "text/html")
+		var b bytes.Buffer
+		require.NoError(t, tfs.Execute(&b, tt.name, template.FuncMap{}, page), tt.name)
+		assert.Equal(t, tt.want, b.String(), tt.name)
+		if tt.err == nil {
+			assert.NoError(t, page.Error(), tt.name)
+			continue
+		}
+		var le *LimitError
+		require.True(t, errors.As(page.Error(), &le), tt.name)
+		assert.Equal(t, tt.err, le, tt.name)
+	}
+
+	// Timeout is reported as deadline
+	page := samplemeta.NewMeta(200, "text/html")
+	tfs.RenderContent("slow", template.FuncMap{}, page)
+	assert.True(t, errors.Is(page.Error(), context.DeadlineExceeded))
+
+	// Cancelled parent context is not a limit error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	page = samplemeta.NewMeta(200, "text/html")
+	tfs.RenderContentContext(ctx, "small", template.FuncMap{}, page)
+	assert.Equal(t, context.Canceled, page.Error())
+
+	// Layout output is limited too
+	page = samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("wide")
+	var b bytes.Buffer
+	err = tfs.Execute(&b, "small", template.FuncMap{}, page)
+	var le *LimitError
+	require.True(t, errors.As(err, &le))
+	assert.Equal(t, &LimitError{Name: "wide", MaxSize: 90}, le)
+
+	// Page sections are limited by page limits
+	page = samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("sections")
+	b.Reset()
+	err = tfs.Execute(&b, "sectioned", template.FuncMap{}, page)
+	require.True(t, errors.As(err, &le))
+	assert.Equal(t, &LimitError{Name: "big", MaxSize: 90}, le)
+
+	// Layout funcs get layout deadline
+	page = samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("waiting")
+	b.Reset()
+	start := time.Now()
+	err = tfs.Execute(&b, "small", template.FuncMap{}, page)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	require.True(t, errors.As(err, &le))
+	assert.Equal(t, &LimitError{Name: "waiting", Timeout: 20 * time.Millisecond}, le)
+}
@@ -0,0 +1,316 @@
+// Package lint implements apitpl templates checks which otherwise fail at request time.
+package lint
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/template/parse"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/internal/parsetree"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+// Issue holds single problem found in templates
+type Issue struct {
+	Path    string // Template file path, empty if issue is not related to file
+	Line    int    // Line number, 0 if unknown
+	Message string
+}
+
+// String returns issue in "path:line: message" form
+func (i Issue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.Path, i.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", i.Path, i.Line, i.Message)
+}
+
+// parseName is the name of every parsed tree, so positions can be extracted from error messages
+const parseName = "lint"
+
+var (
+	// reParseError matches parse error message
+	reParseError = regexp.MustCompile(`^template: ` + parseName + `:(\d+):\s*(.*)$`)
+	// reErrorContext matches node location returned by Tree.ErrorContext
+	reErrorContext = regexp.MustCompile(`^` + parseName + `:(\d+):`)
+	// reTemplateError matches apitpl parse error message
+	reTemplateError = regexp.MustCompile(`(?:^|: )template: (.*?):(\d+):\s*(.*)$`)
+)
+
+// builtins holds names of funcs available in every template
+var builtins = []string{"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print", "printf",
+	"println", "urlquery", "eq", "ge", "gt", "le", "lt", "ne", "content", "block_content", "context"}
+
+// tmpl holds parsed template file
+type tmpl struct {
+	kind  string // includes, layouts or pages
+	name  string
+	path  string
+	trees map[string]*parse.Tree // main tree and templates defined in file
+}
+
+// linter holds lint state
+type linter struct {
+	lfs    *lookupfs.LookupFileSystem
+	funcs  []map[string]interface{}
+	issues []Issue
+}
+
+// Lint parses templates by apitpl.TemplateService and reports its error (if any).
+// Every template file is parsed separately too, so all of parse errors are reported, not only the first one,
+// and templates are checked for
+// references to undefined templates, layouts set via SetLayout or front matter which do not exist,
+// unused includes (if templates are parsed by apitpl successfully),
+// pages and layouts named as includes and templates shadowed by others with the same name.
+// Template funcs are checked for existence if funcs is not nil, otherwise funcs called by templates are stubbed.
+// Returned error means lookup failure
+func Lint(lfs *lookupfs.LookupFileSystem, funcs template.FuncMap) ([]Issue, error) {
+	if err := lfs.LookupAll(); err != nil {
+		return nil, err
+	}
+	l := &linter{lfs: lfs}
+	if funcs != nil {
+		known := map[string]interface{}{}
+		for _, k := range builtins {
+			known[k] = true
+		}
+		for k, v := range funcs {
+			known[k] = v
+		}
+		l.funcs = []map[string]interface{}{known}
+	}
+	includes := l.parseAll("includes", lfs.Includes)
+	layouts := l.parseAll("layouts", lfs.Layouts)
+	pages := l.parseAll("pages", lfs.Pages)
+	if funcs == nil {
+		funcs = stubs(includes, layouts, pages)
+	}
+	tfs, err := apitpl.New(1).Funcs(funcs).LookupFS(lfs).Parse()
+	if err != nil {
+		l.addError(err)
+	} else {
+		for _, name := range tfs.Graph().UnusedIncludes() {
+			l.add(lfs.Includes[name].Path, 0, fmt.Sprintf("include %s is not used", name))
+		}
+	}
+
+	// names defined by includes
+	defined := map[string]bool{}
+	for _, t := range includes {
+		for name := range t.trees {
+			defined[name] = true
+		}
+	}
+	for _, list := range [][]*tmpl{includes, layouts, pages} {
+		for _, t := range list {
+			l.checkRefs(t, defined)
+			if t.kind != "includes" {
+				l.checkLayouts(t)
+			}
+		}
+	}
+
+	for _, kind := range []struct {
+		name  string
+		files map[string]lookupfs.File
+	}{{"layout", lfs.Layouts}, {"page", lfs.Pages}} {
+		for name, f := range kind.files {
+			if inc, ok := lfs.Includes[name]; ok {
+				l.add(f.Path, 0, fmt.Sprintf("%s name %s is the same as include %s", kind.name, name, inc.Path))
+			}
+		}
+	}
+	for _, s := range lfs.Shadowed {
+		l.add(s.Path, 0, fmt.Sprintf("shadowed by %s (both %s are named %q)", s.By, s.Kind, s.Name))
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].Path != l.issues[j].Path {
+			return l.issues[i].Path < l.issues[j].Path
+		}
+		return l.issues[i].Line < l.issues[j].Line
+	})
+	return l.issues, nil
+}
+
+// add adds issue if it was not added before
+func (l *linter) add(path string, line int, msg string) {
+	issue := Issue{Path: path, Line: line, Message: msg}
+	for _, i := range l.issues {
+		if i == issue {
+			return
+		}
+	}
+	l.issues = append(l.issues, issue)
+}
+
+// addError adds apitpl parse error as issue of template file which raised it (if known)
+func (l *linter) addError(err error) {
+	m := reTemplateError.FindStringSubmatch(err.Error())
+	if m == nil {
+		l.add("", 0, err.Error())
+		return
+	}
+	for _, files := range []map[string]lookupfs.File{l.lfs.Includes, l.lfs.Layouts, l.lfs.Pages} {
+		if f, ok := files[m[1]]; ok {
+			line, _ := strconv.Atoi(m[2])
+			l.add(f.Path, line, m[3])
+			return
+		}
+	}
+	l.add("", 0, err.Error())
+}
+
+// stubs returns funcs which are called by templates, so apitpl parses them without funcs check
+func stubs(lists ...[]*tmpl) template.FuncMap {
+	known := map[string]bool{}
+	for _, k := range builtins {
+		known[k] = true
+	}
+	funcs := template.FuncMap{}
+	for _, list := range lists {
+		for _, t := range list {
+			for _, tree := range t.trees {
+				parsetree.Walk(tree.Root, func(node parse.Node) {
+					if n, ok := node.(*parse.IdentifierNode); ok && !known[n.Ident] {
+						funcs[n.Ident] = func(...interface{}) interface{} { return nil }
+					}
+				})
+			}
+		}
+	}
+	return funcs
+}
+
+// parseAll parses files of given kind sorted by name. Files with parse errors are returned without trees
+func (l *linter) parseAll(kind string, files map[string]lookupfs.File) []*tmpl {
+	names := make([]string, 0, len(files))
+	for k := range files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	list := make([]*tmpl, len(names))
+	for i, k := range names {
+		list[i] = &tmpl{kind: kind, name: k, path: files[k].Path}
+		list[i].trees = l.parse(list[i])
+	}
+	return list
+}
+
+// parse parses template file, parse errors are stored as issues
+func (l *linter) parse(t *tmpl) map[string]*parse.Tree {
+	text, err := l.lfs.ReadFile(t.path)
+	if err != nil {
+		l.add(t.path, 0, err.Error())
+		return nil
+	}
+	meta, text, err := lookupfs.ParseFrontMatter(text)
+	if err != nil {
+		l.add(t.path, 1, err.Error())
+		return nil
+	}
+	if meta != nil && meta.Layout != "" {
+		if _, ok := l.lfs.Layouts[meta.Layout]; !ok {
+			l.add(t.path, 1, fmt.Sprintf("front matter layout %s does not exist", meta.Layout))
+		}
+	}
+	tree := parse.New(parseName)
+	tree.Mode = parse.SkipFuncCheck
+	if l.funcs != nil {
+		tree.Mode = 0
+	}
+	trees := map[string]*parse.Tree{}
+	if _, err = tree.Parse(text, "", "", trees, l.funcs...); err != nil {
+		if m := reParseError.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			l.add(t.path, line, m[2])
+		} else {
+			l.add(t.path, 0, err.Error())
+		}
+		return nil
+	}
+	// main tree is named as template unless it is empty and template with this name is defined in file
+	main := trees[parseName]
+	delete(trees, parseName)
+	if _, ok := trees[t.name]; !ok || !parse.IsEmptyTree(main.Root) {
+		trees[t.name] = main
+	}
+	return trees
+}
+
+// checkRefs reports references to templates which are not defined in includes or template itself
+func (l *linter) checkRefs(t *tmpl, defined map[string]bool) {
+	for _, tree := range t.trees {
+		parsetree.Walk(tree.Root, func(node parse.Node) {
+			n, ok := node.(*parse.TemplateNode)
+			if !ok || defined[n.Name] {
+				return
+			}
+			if _, ok := t.trees[n.Name]; !ok {
+				l.add(t.path, line(tree, n), fmt.Sprintf("template %s is not defined", n.Name))
+			}
+		})
+	}
+}
+
+// checkLayouts reports SetLayout calls with names of layouts which do not exist
+func (l *linter) checkLayouts(t *tmpl) {
+	for _, tree := range t.trees {
+		parsetree.Walk(tree.Root, func(node parse.Node) {
+			pipe, ok := node.(*parse.PipeNode)
+			if !ok {
+				return
+			}
+			for i, cmd := range pipe.Cmds {
+				if !isSetLayout(cmd.Args[0]) {
+					continue
+				}
+				var arg parse.Node
+				if len(cmd.Args) > 1 {
+					arg = cmd.Args[1]
+				} else if i > 0 && len(pipe.Cmds[i-1].Args) == 1 {
+					// "name" | .SetLayout
+					arg = pipe.Cmds[i-1].Args[0]
+				}
+				s, ok := arg.(*parse.StringNode)
+				if !ok || s.Text == "" {
+					continue
+				}
+				if _, ok := l.lfs.Layouts[s.Text]; !ok {
+					l.add(t.path, line(tree, cmd), fmt.Sprintf("layout %s does not exist", s.Text))
+				}
+			}
+		})
+	}
+}
+
+// isSetLayout returns true if node is SetLayout method call
+func isSetLayout(node parse.Node) bool {
+	var ident []string
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		ident = n.Ident
+	case *parse.VariableNode:
+		ident = n.Ident
+	default:
+		return false
+	}
+	return len(ident) != 0 && ident[len(ident)-1] == "SetLayout"
+}
+
+// line returns line of node in tree
+func line(tree *parse.Tree, node parse.Node) int {
+	location, _ := tree.ErrorContext(node)
+	if m := reErrorContext.FindStringSubmatch(location); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
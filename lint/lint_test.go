@@ -0,0 +1,82 @@
+package lint
+
+import (
+	"errors"
+	"html/template"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+var cfg = lookupfs.Config{
+	Includes:  "inc",
+	Layouts:   "layout",
+	Pages:     "page",
+	Ext:       ".tmpl",
+	DefLayout: "default",
+	Index:     "index",
+	Root:      "testdata",
+}
+
+func TestLint(t *testing.T) {
+	issues, err := Lint(lookupfs.New(cfg), nil)
+	require.NoError(t, err)
+	var got []string
+	for _, i := range issues {
+		got = append(got, i.String())
+	}
+	want := []string{
+		"inc/foot.tmpl:2: template missing is not defined",
+		"inc/unused.tmpl: include unused is not used",
+		"page/front.tmpl:1: front matter layout missing does not exist",
+		"page/item.tmpl: page name item is the same as include testdata/inc/item.tmpl",
+		"page/re.tmpl: shadowed by testdata/page/reindex.tmpl (both pages are named \"re\")",
+		"page/ref.tmpl:2: template nope is not defined",
+		"page/wide.tmpl:2: layout wide does not exist",
+		"page/wide.tmpl:3: layout wide2 does not exist",
+	}
+	for i := range want {
+		want[i] = filepath.Join("testdata", want[i])
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestLintParseError(t *testing.T) {
+	c := cfg
+	c.Root = filepath.Join("testdata", "broken")
+	issues, err := Lint(lookupfs.New(c), nil)
+	require.NoError(t, err)
+	// unused includes are not known when templates are not parsed
+	assert.Equal(t, []Issue{
+		{Path: filepath.Join("testdata", "broken", "page/bad.tmpl"), Line: 2, Message: "missing value for if"},
+	}, issues)
+}
+
+func TestLintFuncs(t *testing.T) {
+	issues, err := Lint(lookupfs.New(cfg), template.FuncMap{})
+	require.NoError(t, err)
+	assert.Contains(t, issues, Issue{
+		Path:    filepath.Join("testdata", "page/funcs.tmpl"),
+		Line:    1,
+		Message: `function "unknown" not defined`,
+	})
+}
+
+func TestAddError(t *testing.T) {
+	lfs := lookupfs.New(cfg)
+	require.NoError(t, lfs.LookupAll())
+	l := &linter{lfs: lfs}
+	l.addError(errors.New(`parse template: template: ref:3: function "user" not defined`))
+	l.addError(errors.New(`template: menu:1: unexpected EOF`))
+	l.addError(errors.New(`default layout (default) does not exist`))
+	assert.Equal(t, []Issue{
+		{Path: filepath.Join("testdata", "page/ref.tmpl"), Line: 3, Message: `function "user" not defined`},
+		{Path: filepath.Join("testdata", "inc/menu.tmpl"), Line: 1, Message: "unexpected EOF"},
+		{Message: "default layout (default) does not exist"},
+	}, l.issues)
+	assert.Equal(t, "default layout (default) does not exist", l.issues[2].String())
+}
@@ -0,0 +1,40 @@
+package lookupfs
+
+import (
+	"sort"
+)
+
+// Changes holds names of added, removed and modified files
+type Changes struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// Empty returns true if there are no changes
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// Diff compares two lookup results and returns sorted names of changed files.
+// File is treated as modified if its path or modification time differs.
+func Diff(prev, next map[string]File) Changes {
+	var c Changes
+	for k, v := range next {
+		f, ok := prev[k]
+		if !ok {
+			c.Added = append(c.Added, k)
+		} else if f.Path != v.Path || !f.ModTime.Equal(v.ModTime) {
+			c.Modified = append(c.Modified, k)
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			c.Removed = append(c.Removed, k)
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	sort.Strings(c.Modified)
+	return c
+}
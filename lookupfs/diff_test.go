@@ -0,0 +1,33 @@
+package lookupfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	now := time.Now()
+	prev := map[string]File{
+		"same":    {Path: "same.html", ModTime: now},
+		"touched": {Path: "touched.html", ModTime: now},
+		"moved":   {Path: "moved.html", ModTime: now},
+		"removed": {Path: "removed.html", ModTime: now},
+	}
+	next := map[string]File{
+		"same":    {Path: "same.html", ModTime: now},
+		"touched": {Path: "touched.html", ModTime: now.Add(time.Second)},
+		"moved":   {Path: "moved/index.html", ModTime: now},
+		"b_added": {Path: "b_added.html", ModTime: now},
+		"a_added": {Path: "a_added.html", ModTime: now},
+	}
+	c := Diff(prev, next)
+	assert.Equal(t, Changes{
+		Added:    []string{"a_added", "b_added"},
+		Removed:  []string{"removed"},
+		Modified: []string{"moved", "touched"},
+	}, c)
+	assert.False(t, c.Empty())
+	assert.True(t, Diff(next, next).Empty())
+}
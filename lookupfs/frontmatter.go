@@ -0,0 +1,81 @@
+package lookupfs
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter holds page metadata defined in template header
+type FrontMatter struct {
+	Layout      string   `yaml:"layout" toml:"layout"`
+	Title       string   `yaml:"title" toml:"title"`
+	ContentType string   `yaml:"content_type" toml:"content_type"`
+	Status      int      `yaml:"status" toml:"status"`
+	Hidden      bool     `yaml:"hidden" toml:"hidden"`
+	Methods     []string `yaml:"methods" toml:"methods"`
+	Timeout     string   `yaml:"timeout" toml:"timeout"`   // Execution time limit like "2s"
+	MaxSize     int      `yaml:"max_size" toml:"max_size"` // Output size limit in bytes
+}
+
+// TimeoutDuration returns parsed Timeout (zero if not set)
+func (fm FrontMatter) TimeoutDuration() time.Duration {
+	d, _ := time.ParseDuration(fm.Timeout) // validated by ParseFrontMatter
+	return d
+}
+
+// Front matter delimiters
+const (
+	yamlDelimiter = "---"
+	tomlDelimiter = "+++"
+)
+
+// ParseFrontMatter extracts front matter from the beginning of template text.
+// YAML front matter is placed between "---" lines, TOML front matter - between "+++" lines.
+// Returned text has front matter replaced by template comment, so line numbers in template errors are kept.
+// If text has no front matter, nil is returned with text unchanged.
+func ParseFrontMatter(text string) (*FrontMatter, string, error) {
+	delim := ""
+	for _, d := range []string{yamlDelimiter, tomlDelimiter} {
+		if strings.HasPrefix(text, d+"\n") || strings.HasPrefix(text, d+"\r\n") {
+			delim = d
+			break
+		}
+	}
+	if delim == "" {
+		return nil, text, nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\r\n") == delim {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, "", errors.Errorf("front matter: closing %s not found", delim)
+	}
+	header := strings.Join(lines[1:end], "")
+	fm := &FrontMatter{}
+	var err error
+	if delim == yamlDelimiter {
+		err = yaml.Unmarshal([]byte(header), fm)
+	} else {
+		err = toml.Unmarshal([]byte(header), fm)
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err, "front matter")
+	}
+	if fm.Timeout != "" {
+		if _, err = time.ParseDuration(fm.Timeout); err != nil {
+			return nil, "", errors.Wrap(err, "front matter timeout")
+		}
+	}
+	body := strings.Join(lines[end+1:], "")
+	// comment ends at the line of closing delimiter
+	return fm, "{{/*" + strings.Repeat("\n", end) + "*/ -}}" + lines[end][len(delim):] + body, nil
+}
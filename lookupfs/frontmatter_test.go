@@ -0,0 +1,54 @@
+package lookupfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		fm   *FrontMatter
+		body string
+	}{
+		{name: "None", text: "page\n---\n", body: "page\n---\n"},
+		{name: "YAML",
+			text: "---\nlayout: wide\ntitle: Login\nstatus: 202\nhidden: true\nmethods: [GET, POST]\n---\n<h1>Login</h1>\n",
+			fm:   &FrontMatter{Layout: "wide", Title: "Login", Status: 202, Hidden: true, Methods: []string{"GET", "POST"}},
+			body: "{{/*\n\n\n\n\n\n*/ -}}\n<h1>Login</h1>\n",
+		},
+		{name: "TOML",
+			text: "+++\ncontent_type = \"text/plain\"\n+++\ntext",
+			fm:   &FrontMatter{ContentType: "text/plain"},
+			body: "{{/*\n\n*/ -}}\ntext",
+		},
+		{name: "Limits",
+			text: "+++\ntimeout = \"150ms\"\nmax_size = 1024\n+++\ntext",
+			fm:   &FrontMatter{Timeout: "150ms", MaxSize: 1024},
+			body: "{{/*\n\n\n*/ -}}\ntext",
+		},
+		{name: "Empty", text: "---\n---", fm: &FrontMatter{}, body: "{{/*\n*/ -}}"},
+	}
+	for _, tt := range tests {
+		fm, body, err := ParseFrontMatter(tt.text)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.fm, fm, tt.name)
+		assert.Equal(t, tt.body, body, tt.name)
+	}
+}
+
+func TestParseFrontMatterErrors(t *testing.T) {
+	_, _, err := ParseFrontMatter("---\ntitle: x\n")
+	assert.EqualError(t, err, "front matter: closing --- not found")
+
+	_, _, err = ParseFrontMatter("---\ntitle: [x\n---\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "front matter: yaml")
+
+	_, _, err = ParseFrontMatter("---\ntimeout: 2 sec\n---\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "front matter timeout")
+}
@@ -27,6 +27,7 @@ type Config struct {
 	Index      string `long:"index" default:"index" description:"Index page name"`
 	DefLayout  string `long:"def_layout" default:"default" description:"Default layout template"`
 	HidePrefix string `long:"hide_prefix" default:"." description:"Treat files with this prefix as hidden"`
+	Variants   string `long:"variants" default:"html,json,xml,txt" description:"Comma separated list of page variant extensions"`
 }
 
 // codebeat:enable[TOO_MANY_IVARS]
@@ -57,6 +58,15 @@ type File struct {
 	ModTime time.Time
 }
 
+// Shadowed holds template file which is not used because the other file of the same kind has the same name
+// (like "page/re.tmpl" and "page/reindex.tmpl" after index suffix removal)
+type Shadowed struct {
+	Kind string // includes, layouts or pages
+	Name string // template name
+	Path string // path of file which is not used
+	By   string // path of file which is used
+}
+
 // LookupFileSystem holds filesystem with template lookup functionality
 type LookupFileSystem struct {
 	config   Config
@@ -64,6 +74,28 @@ type LookupFileSystem struct {
 	Includes map[string]File
 	Layouts  map[string]File
 	Pages    map[string]File
+	Shadowed []Shadowed // files shadowed by others, found by LookupAll
+}
+
+// lookupResult holds files found by lookup
+type lookupResult struct {
+	includes map[string]File
+	layouts  map[string]File
+	pages    map[string]File
+	shadowed []Shadowed
+}
+
+// newLookupResult returns empty lookupResult
+func newLookupResult() *lookupResult {
+	return &lookupResult{includes: map[string]File{}, layouts: map[string]File{}, pages: map[string]File{}}
+}
+
+// add adds file of given kind. If there is the file with the same name already, it is stored as shadowed
+func (r *lookupResult) add(kind string, files map[string]File, name string, f File) {
+	if prev, ok := files[name]; ok {
+		r.shadowed = append(r.shadowed, Shadowed{Kind: kind, Name: name, Path: prev.Path, By: f.Path})
+	}
+	files[name] = f
 }
 
 // New creates LookupFileSystem
@@ -89,6 +121,65 @@ func (lfs LookupFileSystem) DefaultLayout() string {
 	return lfs.config.DefLayout
 }
 
+// Variants returns page variant extensions listed in Config.Variants
+func (lfs *LookupFileSystem) Variants() []string {
+	var variants []string
+	for _, v := range strings.Split(lfs.config.Variants, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// Variant splits template name into base name and variant.
+// Variant is a name extension listed in Config.Variants, e.g. "report.json" is the "json" variant of "report".
+// Empty variant is returned if name has no such extension
+func (lfs *LookupFileSystem) Variant(name string) (base, variant string) {
+	i := strings.LastIndex(name, ".")
+	if i <= strings.LastIndex(name, "/")+1 {
+		return name, ""
+	}
+	ext := name[i+1:]
+	for _, v := range lfs.Variants() {
+		if v == ext {
+			return name[:i], ext
+		}
+	}
+	return name, ""
+}
+
+// PageLayout returns default layout name for given page.
+// Layout named as page directory is used if exists, or as its parent directory and so on.
+// DefaultLayout is returned if there are no such layouts.
+// Page variant uses layouts of the same variant (e.g. "admin.json" for "admin/report.json")
+// and has no layout if there are no such layouts
+func (lfs LookupFileSystem) PageLayout(page string) string {
+	page, variant := lfs.Variant(page)
+	suffix := ""
+	if variant != "" {
+		suffix = "." + variant
+	}
+	dir := page
+	for {
+		i := strings.LastIndex(dir, "/")
+		if i < 0 {
+			break
+		}
+		dir = dir[:i]
+		if _, ok := lfs.Layouts[dir+suffix]; ok {
+			return dir + suffix
+		}
+	}
+	if variant == "" {
+		return lfs.DefaultLayout()
+	}
+	if _, ok := lfs.Layouts[lfs.DefaultLayout()+suffix]; ok {
+		return lfs.DefaultLayout() + suffix
+	}
+	return ""
+}
+
 // IncludeNames return sorted slice of include names
 func (lfs LookupFileSystem) IncludeNames() []string {
 	return mapKeys(lfs.Includes, "", false)
@@ -106,19 +197,38 @@ func (lfs LookupFileSystem) PageNames(hide bool) []string {
 
 // LookupAll scan filesystem for includes,pages and layouts
 func (lfs *LookupFileSystem) LookupAll() (err error) {
-	if lfs.config.UseSuffix {
-		err = lfs.lookupFilesBySuffix()
-	} else {
-		err = lfs.lookupFilesByPrefix()
+	r := newLookupResult()
+	if err = lfs.lookup(r); err != nil {
+		return
 	}
-	if err == nil {
-		if _, ok := lfs.Layouts[lfs.DefaultLayout()]; !ok {
-			err = errors.Errorf("default layout (%s) does not exists", lfs.DefaultLayout())
-		}
+	if _, ok := r.layouts[lfs.DefaultLayout()]; !ok {
+		return errors.Errorf("default layout (%s) does not exists", lfs.DefaultLayout())
 	}
+	// Replace maps, so removed files will not be found anymore
+	lfs.Includes, lfs.Layouts, lfs.Pages, lfs.Shadowed = r.includes, r.layouts, r.pages, r.shadowed
 	return
 }
 
+// Changed scans filesystem and reports if any of templates was added, removed or modified
+// since last LookupAll call. Lookup results are not changed.
+func (lfs LookupFileSystem) Changed() (bool, error) {
+	r := newLookupResult()
+	if err := lfs.lookup(r); err != nil {
+		return false, err
+	}
+	return !Diff(lfs.Includes, r.includes).Empty() ||
+		!Diff(lfs.Layouts, r.layouts).Empty() ||
+		!Diff(lfs.Pages, r.pages).Empty(), nil
+}
+
+// lookup fills given result with files found in filesystem
+func (lfs LookupFileSystem) lookup(r *lookupResult) error {
+	if lfs.config.UseSuffix {
+		return lfs.lookupFilesBySuffix(r)
+	}
+	return lfs.lookupFilesByPrefix(r)
+}
+
 // ReadFile reads file via filesystem method
 func (lfs LookupFileSystem) ReadFile(name string) (string, error) {
 	f, err := lfs.fs.Open(name)
@@ -134,7 +244,7 @@ func (lfs LookupFileSystem) ReadFile(name string) (string, error) {
 	return s, nil
 }
 
-func (lfs LookupFileSystem) walk(tag, prefix string, files map[string]File) (err error) {
+func (lfs LookupFileSystem) walk(tag, prefix string, r *lookupResult, files map[string]File) (err error) {
 
 	root := filepath.Join(lfs.config.Root, prefix)
 	err = fs.WalkDir(lfs.fs, root, func(path string, f fs.DirEntry, err error) error {
@@ -164,7 +274,7 @@ func (lfs LookupFileSystem) walk(tag, prefix string, files map[string]File) (err
 
 		//fmt.Printf("Found %s -> %s\n", name, path)
 		info,_ := f.Info()
-		files[name] = File{Path: path, ModTime: info.ModTime()}
+		r.add(tag, files, name, File{Path: path, ModTime: info.ModTime()})
 		return nil
 	})
 	if err != nil {
@@ -173,24 +283,24 @@ func (lfs LookupFileSystem) walk(tag, prefix string, files map[string]File) (err
 	return nil
 }
 
-func (lfs *LookupFileSystem) lookupFilesByPrefix() (err error) {
+func (lfs LookupFileSystem) lookupFilesByPrefix(r *lookupResult) (err error) {
 
 	if lfs.config.Includes != "" {
-		if err = lfs.walk("includes", lfs.config.Includes, lfs.Includes); err != nil {
+		if err = lfs.walk("includes", lfs.config.Includes, r, r.includes); err != nil {
 			return
 		}
 	}
-	if err = lfs.walk("layouts", lfs.config.Layouts, lfs.Layouts); err != nil {
+	if err = lfs.walk("layouts", lfs.config.Layouts, r, r.layouts); err != nil {
 		return
 	}
-	if err = lfs.walk("pages", lfs.config.Pages, lfs.Pages); err != nil {
+	if err = lfs.walk("pages", lfs.config.Pages, r, r.pages); err != nil {
 		return
 	}
 
 	return
 }
 
-func (lfs *LookupFileSystem) lookupFilesBySuffix() (err error) {
+func (lfs LookupFileSystem) lookupFilesBySuffix(r *lookupResult) (err error) {
 
 	err = fs.WalkDir(lfs.fs, lfs.config.Root, func(path string, f fs.DirEntry, err error) error {
 		if err != nil {
@@ -217,13 +327,13 @@ func (lfs *LookupFileSystem) lookupFilesBySuffix() (err error) {
 		info,_ := f.Info()
 		value := File{Path: path, ModTime: info.ModTime()}
 		if strings.HasSuffix(name, lfs.config.Includes) {
-			lfs.Includes[strings.TrimSuffix(name, lfs.config.Includes)] = value
+			r.add("includes", r.includes, strings.TrimSuffix(name, lfs.config.Includes), value)
 		} else if strings.HasSuffix(name, lfs.config.Layouts) {
-			lfs.Layouts[strings.TrimSuffix(name, lfs.config.Layouts)] = value
+			r.add("layouts", r.layouts, strings.TrimSuffix(name, lfs.config.Layouts), value)
 		} else {
 			// only page templates must be here
 			// no suffixes => no checking
-			lfs.Pages[name] = value
+			r.add("pages", r.pages, name, value)
 		}
 		return nil
 	})
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -77,3 +78,149 @@ func TestHiddenPages(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "hidden page", s)
 }
+
+func TestChanged(t *testing.T) {
+	cfg := Config{
+		Includes:  "includes",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "lay",
+	}
+	dir := createTestDir(cfg.Ext, []templateFile{
+		{[]string{"includes"}, "inc", `inc1 here`},
+		{[]string{"layouts"}, "lay", `lay1 here`},
+		{[]string{"pages"}, "page", `page1 here`},
+	})
+	defer os.RemoveAll(dir)
+	cfg.Root = dir
+
+	fs := New(cfg)
+	require.NoError(t, fs.LookupAll())
+	changed, err := fs.Changed()
+	require.NoError(t, err)
+	assert.False(t, changed, "nothing changed")
+
+	mtime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "includes", "inc.html"), mtime, mtime))
+	changed, err = fs.Changed()
+	require.NoError(t, err)
+	assert.True(t, changed, "include modified")
+
+	require.NoError(t, fs.LookupAll())
+	require.NoError(t, os.Remove(filepath.Join(dir, "pages", "page.html")))
+	changed, err = fs.Changed()
+	require.NoError(t, err)
+	assert.True(t, changed, "page removed")
+
+	require.NoError(t, fs.LookupAll())
+	assert.Empty(t, fs.PageNames(false), "removed page is not found")
+}
+
+func TestPageLayout(t *testing.T) {
+	cfg := Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Index:     "index",
+	}
+	dir := createTestDir(cfg.Ext, []templateFile{
+		{[]string{"layouts"}, "default", `default`},
+		{[]string{"layouts"}, "admin", `admin`},
+		{[]string{"layouts", "admin"}, "reports", `reports`},
+		{[]string{"pages"}, "index", `index`},
+		{[]string{"pages", "admin"}, "index", `admin index`},
+		{[]string{"pages", "admin", "users"}, "list", `users list`},
+		{[]string{"pages", "admin", "reports"}, "daily", `daily report`},
+		{[]string{"pages", "admin", "reports", "__id"}, "index", `report`},
+		{[]string{"pages", "other"}, "page", `other page`},
+	})
+	defer os.RemoveAll(dir)
+	cfg.Root = dir
+
+	fs := New(cfg)
+	require.NoError(t, fs.LookupAll())
+	want := map[string]string{
+		"/":                   "default",
+		"admin/":              "admin",
+		"admin/users/list":    "admin",
+		"admin/reports/daily": "admin/reports",
+		"admin/reports/:id/":  "admin/reports",
+		"other/page":          "default",
+	}
+	for page, layout := range want {
+		assert.Equal(t, layout, fs.PageLayout(page), page)
+	}
+}
+
+func TestVariant(t *testing.T) {
+	cfg := Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Variants:  "html, json,txt",
+	}
+	dir := createTestDir(cfg.Ext, []templateFile{
+		{[]string{"layouts"}, "default", `default`},
+		{[]string{"layouts"}, "default.txt", `default text`},
+		{[]string{"layouts"}, "admin.json", `admin json`},
+		{[]string{"pages", "admin"}, "report", `report`},
+		{[]string{"pages", "admin"}, "report.json", `report json`},
+		{[]string{"pages", "admin"}, "report.txt", `report text`},
+		{[]string{"pages"}, "report.xml", `report xml`},
+		{[]string{"pages"}, ".hidden", `hidden`},
+	})
+	defer os.RemoveAll(dir)
+	cfg.Root = dir
+
+	fs := New(cfg)
+	require.NoError(t, fs.LookupAll())
+	assert.Equal(t, []string{"html", "json", "txt"}, fs.Variants())
+	tests := []struct {
+		name    string
+		base    string
+		variant string
+		layout  string
+	}{
+		{name: "admin/report", base: "admin/report", layout: "default"},
+		{name: "admin/report.json", base: "admin/report", variant: "json", layout: "admin.json"},
+		{name: "admin/report.txt", base: "admin/report", variant: "txt", layout: "default.txt"},
+		{name: "report.xml", base: "report.xml", layout: "default"},
+		{name: ".hidden", base: ".hidden", layout: "default"},
+	}
+	for _, tt := range tests {
+		base, variant := fs.Variant(tt.name)
+		assert.Equal(t, tt.base, base, tt.name)
+		assert.Equal(t, tt.variant, variant, tt.name)
+		assert.Equal(t, tt.layout, fs.PageLayout(tt.name), tt.name)
+	}
+}
+
+func TestShadowed(t *testing.T) {
+	cfg := Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		Index:     "index",
+		DefLayout: "lay",
+	}
+	dir := createTestDir(cfg.Ext, []templateFile{
+		{[]string{"layouts"}, "lay", `lay here`},
+		{[]string{"pages"}, "re", `re here`},
+		{[]string{"pages"}, "reindex", `reindex here`},
+		{[]string{"pages"}, "page", `page here`},
+	})
+	defer os.RemoveAll(dir)
+	cfg.Root = dir
+
+	fs := New(cfg)
+	require.NoError(t, fs.LookupAll())
+	assert.Equal(t, []Shadowed{{
+		Kind: "pages",
+		Name: "re",
+		Path: filepath.Join(dir, "pages", "re.html"),
+		By:   filepath.Join(dir, "pages", "reindex.html"),
+	}}, fs.Shadowed)
+}
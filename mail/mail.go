@@ -0,0 +1,89 @@
+// Package mail implements mail messages rendering by apitpl templates.
+//
+// Every mail is a page which may have HTML and plain-text parts:
+// HTML part is rendered from page "name", text part - from its "txt" variant "name.txt"
+// (so Variants config must contain "txt"). Parts are rendered with their own layouts
+// (default layout for HTML and "txt" variant of it for text), includes are shared by both of them.
+// Mail metadata (subject, recipients, attachments) is set by templates via Meta methods.
+package mail
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/pkg/errors"
+
+	"github.com/apisite/apitpl"
+)
+
+// TextVariant is the page variant used for text part of mail
+const TextVariant = "txt"
+
+// Service renders mail messages
+type Service struct {
+	tfs  *apitpl.TemplateService
+	from string
+}
+
+// New creates mail Service which renders templates of parsed TemplateService
+func New(tfs *apitpl.TemplateService) *Service {
+	return &Service{tfs: tfs}
+}
+
+// From sets default mail sender
+func (s *Service) From(addr string) *Service {
+	s.from = addr
+	return s
+}
+
+// Render renders mail page with given data & funcs into message.
+// Error is returned if Variants config does not contain TextVariant
+func (s *Service) Render(name string, data interface{}, funcs template.FuncMap) (*Message, error) {
+	textName := name + "." + TextVariant
+	if _, variant := s.tfs.PageVariant(textName); variant != TextVariant {
+		return nil, errors.Errorf("page variant %s is not configured", TextVariant)
+	}
+	pages := map[string]bool{}
+	for _, p := range s.tfs.PageNames(false) {
+		pages[p] = true
+	}
+	if !pages[name] && !pages[textName] {
+		return nil, errors.Errorf("mail %s does not exists", name)
+	}
+	meta := NewMeta(s.tfs.DefaultLayout(), data)
+	meta.SetFrom(s.from)
+	msg := &Message{}
+	var err error
+	if pages[name] {
+		if msg.HTML, err = s.render(name, funcs, meta); err != nil {
+			return nil, err
+		}
+	}
+	if pages[textName] {
+		meta.SetLayout(s.tfs.DefaultLayout())
+		if msg.Text, err = s.render(textName, funcs, meta); err != nil {
+			return nil, err
+		}
+	}
+	msg.From = meta.From()
+	msg.To = meta.To()
+	msg.Subject = meta.Subject()
+	msg.Attachments = meta.Attachments()
+	return msg, nil
+}
+
+// render renders mail part via page content and layout passes
+func (s *Service) render(name string, funcs template.FuncMap, meta *Meta) ([]byte, error) {
+	content := s.tfs.RenderContent(name, funcs, meta)
+	if err := meta.Error(); err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	var buf bytes.Buffer
+	if err := s.tfs.Render(&buf, funcs, meta, content); err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	if err := meta.Error(); err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl"
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+type order struct {
+	ID    int
+	Name  string
+	Email string
+	Total string
+}
+
+func mkService(t *testing.T) *Service {
+	cfg := lookupfs.Config{
+		Includes:  "inc",
+		Layouts:   "layout",
+		Pages:     "page",
+		Ext:       ".tmpl",
+		DefLayout: "default",
+		Root:      "testdata",
+		Variants:  "html,txt",
+	}
+	funcs := template.FuncMap{"HTML": func(s string) template.HTML { return template.HTML(s) }}
+	tfs, err := apitpl.New(64).Funcs(funcs).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+	return New(tfs).From("shop@example.com")
+}
+
+func TestRender(t *testing.T) {
+	srv := mkService(t)
+	data := order{ID: 42, Name: "<Bob>", Email: "bob@example.com", Total: "9.99"}
+	msg, err := srv.Render("order/confirm", data, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "shop@example.com", msg.From)
+	assert.Equal(t, []string{"bob@example.com"}, msg.To)
+	assert.Equal(t, "Order confirmed", msg.Subject, "subject from front matter")
+	assert.Equal(t, "<html><body>\n<p>Dear &lt;Bob&gt;, your order #42 is confirmed.</p>\n\n--\nShop team\n</body></html>\n", string(msg.HTML))
+	assert.Equal(t, "Dear <Bob>, your order #42 is confirmed.\n\n--\nShop team\n", string(msg.Text))
+	require.Len(t, msg.Attachments, 1)
+	assert.Equal(t, "order.csv", msg.Attachments[0].Name)
+	assert.Equal(t, "id,total\n42,9.99\n", string(msg.Attachments[0].Data))
+
+	b, err := msg.Bytes()
+	require.NoError(t, err)
+	m, err := mail.ReadMessage(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, "<bob@example.com>", m.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Order confirmed", subject)
+
+	parts := readParts(t, m.Header.Get("Content-Type"), m.Body)
+	require.Len(t, parts, 2)
+	assert.True(t, strings.HasPrefix(parts[0].Header.Get("Content-Type"), "multipart/alternative"))
+	assert.Equal(t, `attachment; filename=order.csv`, parts[1].Header.Get("Content-Disposition"))
+}
+
+// readParts returns parts of multipart body
+func readParts(t *testing.T, ctype string, body io.Reader) []*multipart.Part {
+	mt, params, err := mime.ParseMediaType(ctype)
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mt)
+	r := multipart.NewReader(body, params["boundary"])
+	var parts []*multipart.Part
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(t, err)
+		parts = append(parts, p)
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	srv := mkService(t)
+	msg, err := srv.Render("hello", order{Name: "Bob"}, template.FuncMap{})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", msg.Subject)
+	assert.Nil(t, msg.HTML)
+
+	b, err := msg.Bytes()
+	require.NoError(t, err)
+	m, err := mail.ReadMessage(bytes.NewReader(b))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", m.Header.Get("Content-Type"))
+	assert.Equal(t, "quoted-printable", m.Header.Get("Content-Transfer-Encoding"))
+	body, err := io.ReadAll(quotedprintable.NewReader(m.Body))
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, Bob!\r\n\r\n--\r\nShop team\r\n", string(body))
+}
+
+func TestRenderError(t *testing.T) {
+	srv := mkService(t)
+	_, err := srv.Render("unknown", nil, nil)
+	assert.EqualError(t, err, "mail unknown does not exists")
+	_, err = srv.Render("order/confirm", nil, nil)
+	assert.Error(t, err, "page needs data")
+
+	cfg := lookupfs.Config{
+		Includes:  "inc",
+		Layouts:   "layout",
+		Pages:     "page",
+		Ext:       ".tmpl",
+		DefLayout: "default",
+		Root:      "testdata",
+		Variants:  "html",
+	}
+	tfs, err := apitpl.New(64).Funcs(template.FuncMap{"HTML": func(s string) template.HTML { return template.HTML(s) }}).
+		LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+	_, err = New(tfs).Render("order/confirm", order{ID: 42}, nil)
+	assert.EqualError(t, err, "page variant txt is not configured")
+}
+
+func TestMessageHeader(t *testing.T) {
+	date := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	msg := Message{
+		From:    "Shop <shop@example.com>",
+		To:      []string{"Jürgen Müller <jm@example.com>", "bob@example.com"},
+		Subject: "Привет",
+		Date:    date,
+	}
+	b, err := msg.Bytes()
+	require.NoError(t, err)
+	m, err := mail.ReadMessage(bytes.NewReader(b))
+	require.NoError(t, err)
+	to, err := m.Header.AddressList("To")
+	require.NoError(t, err)
+	assert.Equal(t, []*mail.Address{{Name: "Jürgen Müller", Address: "jm@example.com"}, {Address: "bob@example.com"}}, to)
+	assert.NotContains(t, m.Header.Get("To"), "ü", "non-ASCII name is encoded")
+	assert.Equal(t, `"Shop" <shop@example.com>`, m.Header.Get("From"))
+	got, err := m.Header.Date()
+	require.NoError(t, err)
+	assert.True(t, date.Equal(got))
+	assert.Regexp(t, `^<[0-9a-f]{32}@example\.com>$`, m.Header.Get("Message-ID"))
+
+	// Date and Message-ID are set by default
+	b, err = Message{From: "shop@example.com"}.Bytes()
+	require.NoError(t, err)
+	m, err = mail.ReadMessage(bytes.NewReader(b))
+	require.NoError(t, err)
+	_, err = m.Header.Date()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m.Header.Get("Message-ID"))
+}
+
+func TestMessageHeaderInjection(t *testing.T) {
+	tests := []Message{
+		{From: "shop@example.com\r\nBcc: spam@example.com"},
+		{To: []string{"bob@example.com\nBcc: spam@example.com"}},
+		{Subject: "Hello\r\nBcc: spam@example.com"},
+		{MessageID: "<id@example.com>\r\nBcc: spam@example.com"},
+		{Attachments: []Attachment{{Name: "a.txt\r\nBcc: spam@example.com"}}},
+		{To: []string{"not an address"}},
+	}
+	for _, msg := range tests {
+		b, err := msg.Bytes()
+		assert.Error(t, err, "%+v", msg)
+		assert.Nil(t, b)
+	}
+}
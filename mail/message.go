@@ -0,0 +1,271 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Attachment holds mail attachment
+type Attachment struct {
+	Name        string
+	ContentType string // detected by Name extension if empty
+	Data        []byte
+}
+
+// Message holds rendered mail message
+type Message struct {
+	From        string
+	To          []string
+	Subject     string
+	Date        time.Time // Current time is used if not set
+	MessageID   string    // Message-ID header value (like "<id@example.com>"), generated if not set
+	HTML        []byte
+	Text        []byte
+	Attachments []Attachment
+}
+
+// part holds MIME entity
+type part struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// base64LineLen is the max length of base64 encoded line
+const base64LineLen = 76
+
+// WriteTo writes message in MIME format.
+// Message with both HTML and text parts is written as multipart/alternative,
+// message with attachments - as multipart/mixed
+func (m Message) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if err := m.write(&buf); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// Bytes returns message in MIME format
+func (m Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := m.write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write writes message headers and body to buf.
+// Addresses are written in RFC 5322 form (with encoded names), header values with line breaks are rejected
+func (m Message) write(buf *bytes.Buffer) error {
+	header, err := m.header()
+	if err != nil {
+		return err
+	}
+	body, err := m.body()
+	if err != nil {
+		return err
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	writeHeader(buf, header)
+	writeHeader(buf, body.header)
+	buf.WriteString("\r\n")
+	buf.Write(body.body)
+	return nil
+}
+
+// header returns message header
+func (m Message) header() (textproto.MIMEHeader, error) {
+	header := textproto.MIMEHeader{}
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	header.Set("Date", date.Format(time.RFC1123Z))
+	from := ""
+	if m.From != "" {
+		addr, err := mail.ParseAddress(m.From)
+		if err != nil {
+			return nil, errors.Wrapf(err, "from address %q", m.From)
+		}
+		from = addr.Address
+		header.Set("From", addr.String())
+	}
+	if len(m.To) != 0 {
+		to := make([]string, len(m.To))
+		for i, s := range m.To {
+			addr, err := mail.ParseAddress(s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "to address %q", s)
+			}
+			to[i] = addr.String()
+		}
+		header.Set("To", strings.Join(to, ", "))
+	}
+	id := m.MessageID
+	if id == "" {
+		var err error
+		if id, err = messageID(from); err != nil {
+			return nil, err
+		}
+	}
+	header["Message-ID"] = []string{id} // not canonical key, so it is written as is
+	if err := checkHeaderValue("Subject", m.Subject); err != nil {
+		return nil, err
+	}
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	for k, v := range header {
+		if err := checkHeaderValue(k, v[0]); err != nil {
+			return nil, err
+		}
+	}
+	return header, nil
+}
+
+// messageID returns new unique Message-ID for sender address
+func messageID(from string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "message id")
+	}
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">", nil
+}
+
+// checkHeaderValue returns error if header value contains line breaks
+func checkHeaderValue(key, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.Errorf("header %s contains line break", key)
+	}
+	return nil
+}
+
+// body returns message body entity
+func (m Message) body() (part, error) {
+	var parts []part
+	if m.Text != nil {
+		parts = append(parts, textPart("text/plain; charset=utf-8", m.Text))
+	}
+	if m.HTML != nil {
+		parts = append(parts, textPart("text/html; charset=utf-8", m.HTML))
+	}
+	var body part
+	switch len(parts) {
+	case 0:
+		body = textPart("text/plain; charset=utf-8", nil)
+	case 1:
+		body = parts[0]
+	default:
+		var err error
+		if body, err = multiPart("alternative", parts); err != nil {
+			return part{}, err
+		}
+	}
+	if len(m.Attachments) == 0 {
+		return body, nil
+	}
+	parts = []part{body}
+	for _, a := range m.Attachments {
+		if err := checkHeaderValue("Content-Type", a.ContentType); err != nil {
+			return part{}, err
+		}
+		if err := checkHeaderValue("Content-Disposition", a.Name); err != nil {
+			return part{}, err
+		}
+		parts = append(parts, attachmentPart(a))
+	}
+	return multiPart("mixed", parts)
+}
+
+// textPart returns quoted-printable encoded text entity
+func textPart(ctype string, text []byte) part {
+	var buf bytes.Buffer
+	qw := quotedprintable.NewWriter(&buf)
+	qw.Write(text)
+	qw.Close()
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {ctype},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: buf.Bytes(),
+	}
+}
+
+// attachmentPart returns base64 encoded attachment entity
+func attachmentPart(a Attachment) part {
+	ctype := a.ContentType
+	if ctype == "" {
+		ctype = mime.TypeByExtension(filepath.Ext(a.Name))
+	}
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	s := base64.StdEncoding.EncodeToString(a.Data)
+	var buf bytes.Buffer
+	for len(s) > base64LineLen {
+		buf.WriteString(s[:base64LineLen] + "\r\n")
+		s = s[base64LineLen:]
+	}
+	buf.WriteString(s)
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {ctype},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		},
+		body: buf.Bytes(),
+	}
+}
+
+// multiPart returns multipart entity of given subtype
+func multiPart(subtype string, parts []part) (part, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		w, err := mw.CreatePart(p.header)
+		if err != nil {
+			return part{}, err
+		}
+		if _, err = w.Write(p.body); err != nil {
+			return part{}, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return part{}, err
+	}
+	return part{
+		header: textproto.MIMEHeader{"Content-Type": {"multipart/" + subtype + "; boundary=" + mw.Boundary()}},
+		body:   buf.Bytes(),
+	}, nil
+}
+
+// writeHeader writes entity header sorted by key
+func writeHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+}
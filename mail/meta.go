@@ -0,0 +1,85 @@
+package mail
+
+// Meta holds mail metadata which is set by mail templates.
+// It is passed to templates as MetaData, and Render data is available as .Data
+type Meta struct {
+	Data        interface{}
+	subject     string
+	from        string
+	to          []string
+	attachments []Attachment
+	layout      string
+	error       error
+}
+
+// NewMeta returns new Meta with given layout and template data
+func NewMeta(layout string, data interface{}) *Meta {
+	return &Meta{layout: layout, Data: data}
+}
+
+// SetSubject sets mail subject
+func (m *Meta) SetSubject(subject string) string { m.subject = subject; return "" }
+
+// SetTitle sets mail subject, so front matter title is used as subject
+func (m *Meta) SetTitle(subject string) string { return m.SetSubject(subject) }
+
+// Subject returns mail subject
+func (m Meta) Subject() string { return m.subject }
+
+// SetFrom sets mail sender
+func (m *Meta) SetFrom(addr string) string { m.from = addr; return "" }
+
+// From returns mail sender
+func (m Meta) From() string { return m.from }
+
+// AddTo adds mail recipients. Recipient which was added already is skipped
+func (m *Meta) AddTo(addrs ...string) string {
+	for _, addr := range addrs {
+		if !contains(m.to, addr) {
+			m.to = append(m.to, addr)
+		}
+	}
+	return ""
+}
+
+// To returns mail recipients
+func (m Meta) To() []string { return m.to }
+
+// Attach adds attachment with given file name and content.
+// Attachment with the same name is replaced, so both of mail parts may attach it
+func (m *Meta) Attach(name, content string) string {
+	a := Attachment{Name: name, Data: []byte(content)}
+	for i := range m.attachments {
+		if m.attachments[i].Name == name {
+			m.attachments[i] = a
+			return ""
+		}
+	}
+	m.attachments = append(m.attachments, a)
+	return ""
+}
+
+// Attachments returns mail attachments
+func (m Meta) Attachments() []Attachment { return m.attachments }
+
+// SetLayout sets mail part layout
+func (m *Meta) SetLayout(name string) string { m.layout = name; return "" }
+
+// Layout returns mail part layout
+func (m Meta) Layout() string { return m.layout }
+
+// SetError sets error by template engine
+func (m *Meta) SetError(e error) { m.error = e }
+
+// Error returns template error
+func (m Meta) Error() error { return m.error }
+
+// contains returns true if slice contains s
+func contains(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
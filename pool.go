@@ -0,0 +1,37 @@
+package apitpl
+
+import (
+	"html/template"
+	"sync"
+
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+// templatePool holds parsed template and its clones ready for execution.
+// Funcs are set to template per request, so every clone is used by single goroutine at a time.
+type templatePool struct {
+	tmpl  Template         // parsed template, never executed so it can be cloned
+	funcs template.FuncMap // funcs used for parsing
+	meta  *lookupfs.FrontMatter
+	deps  *templateDeps
+	pool  sync.Pool
+}
+
+// newTemplatePool creates pool for template parsed with given funcs
+func newTemplatePool(tmpl Template, funcs template.FuncMap) *templatePool {
+	return &templatePool{tmpl: tmpl, funcs: funcs}
+}
+
+// get returns template clone for exclusive use
+func (tp *templatePool) get() (Template, error) {
+	if tmpl, ok := tp.pool.Get().(Template); ok {
+		return tmpl, nil
+	}
+	return tp.tmpl.Clone()
+}
+
+// put returns template clone to pool.
+// Funcs of previous request are replaced by parse time funcs, so they will not be called by next one
+func (tp *templatePool) put(tmpl Template) {
+	tp.pool.Put(tmpl.Funcs(tp.funcs))
+}
@@ -0,0 +1,43 @@
+package apitpl
+
+import (
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+func TestPoolFuncsReset(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `{{ content }}`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `{{ who }}`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tfs, err := New(64).
+		Funcs(template.FuncMap{"who": func() string { return "proto" }}).
+		LookupFS(lookupfs.New(cfg)).
+		Parse()
+	require.NoError(t, err)
+
+	page := samplemeta.NewMeta(200, "text/html")
+	buf := tfs.RenderContent("page", template.FuncMap{"who": func() string { return "first" }}, page)
+	require.NotNil(t, buf)
+	assert.Equal(t, "first", buf.String())
+
+	// clone of previous request is reused without its funcs
+	buf = tfs.RenderContent("page", template.FuncMap{}, page)
+	require.NotNil(t, buf)
+	assert.Equal(t, "proto", buf.String())
+}
@@ -0,0 +1,75 @@
+package apitpl
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"reflect"
+)
+
+// FuncProvider provides funcs which depend on request
+type FuncProvider interface {
+	// Proto returns placeholder funcs used for parsing
+	Proto() template.FuncMap
+	// Bind returns funcs for request. They must have the same names and signatures as Proto ones
+	Bind(ctx context.Context, r *http.Request) template.FuncMap
+}
+
+// RequestFuncs is a FuncProvider defined by prototype funcs and binder
+type RequestFuncs struct {
+	Prototype template.FuncMap
+	Binder    func(ctx context.Context, r *http.Request) template.FuncMap
+}
+
+// Proto returns Prototype funcs
+func (rf RequestFuncs) Proto() template.FuncMap { return rf.Prototype }
+
+// Bind returns funcs created by Binder
+func (rf RequestFuncs) Bind(ctx context.Context, r *http.Request) template.FuncMap {
+	return rf.Binder(ctx, r)
+}
+
+// FuncProviders registers request funcs providers. Their prototypes are loaded as ProtoFuncs
+func (tfs *TemplateService) FuncProviders(providers ...FuncProvider) *TemplateService {
+	for _, p := range providers {
+		tfs.providers = append(tfs.providers, p)
+		tfs.ProtoFuncs(p.Proto())
+	}
+	return tfs
+}
+
+// BindFuncs returns new funcmap with funcs of all providers bound to request.
+// *FuncsError is returned if any provider did not bind its proto func or bound it with other signature.
+// Returned funcmap belongs to the request, so it may be changed and passed to RenderContent and Render
+func (tfs *TemplateService) BindFuncs(ctx context.Context, r *http.Request) (template.FuncMap, error) {
+	funcs := template.FuncMap{}
+	e := &FuncsError{}
+	for _, p := range tfs.providers {
+		bound := p.Bind(ctx, r)
+		for k, proto := range p.Proto() {
+			v, ok := bound[k]
+			if !ok {
+				e.Missing = append(e.Missing, k)
+				continue
+			}
+			if want, got := reflect.TypeOf(proto), reflect.TypeOf(v); want != got {
+				e.Mismatch = append(e.Mismatch, FuncMismatch{Name: k, Want: want, Got: got})
+				continue
+			}
+			funcs[k] = v
+		}
+	}
+	if err := e.result(); err != nil {
+		return nil, err
+	}
+	return funcs, nil
+}
+
+// copyFuncs returns copy of funcs with room for extra items
+func copyFuncs(funcs template.FuncMap, extra int) template.FuncMap {
+	c := make(template.FuncMap, len(funcs)+extra)
+	for k, v := range funcs {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,72 @@
+package apitpl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+type userKey struct{}
+
+func TestFuncProviders(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `{{ user }} {{ path }}`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	provider := RequestFuncs{
+		Prototype: template.FuncMap{
+			"user": func() string { return "" },
+			"path": func() string { return "" },
+		},
+		Binder: func(ctx context.Context, r *http.Request) template.FuncMap {
+			return template.FuncMap{
+				"user": func() string { return ctx.Value(userKey{}).(string) },
+				"path": func() string { return r.URL.Path },
+			}
+		},
+	}
+	tfs, err := New(64).FuncProviders(provider).StrictFuncs(true).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/page", nil)
+	funcs, err := tfs.BindFuncs(context.WithValue(r.Context(), userKey{}, "Joe"), r)
+	require.NoError(t, err)
+	page := samplemeta.NewMeta(200, "text/html")
+	var b bytes.Buffer
+	require.NoError(t, tfs.Execute(&b, "page", funcs, page))
+	require.NoError(t, page.Error())
+	assert.Equal(t, "[Joe /page]", b.String())
+	assert.NotContains(t, funcs, "content", "Render does not change funcs")
+
+	tfs.FuncProviders(RequestFuncs{
+		Prototype: template.FuncMap{"id": func() int { return 0 }, "name": func() string { return "" }},
+		Binder: func(ctx context.Context, r *http.Request) template.FuncMap {
+			return template.FuncMap{"id": func() string { return "" }}
+		},
+	})
+	_, err = tfs.BindFuncs(r.Context(), r)
+	var fe *FuncsError
+	require.True(t, errors.As(err, &fe))
+	assert.Equal(t, []string{"name"}, fe.Missing)
+	require.Len(t, fe.Mismatch, 1)
+	assert.Equal(t, "id", fe.Mismatch[0].Name)
+}
@@ -0,0 +1,141 @@
+package apitpl
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+// Report holds template changes found by Refresh
+type Report struct {
+	Includes lookupfs.Changes
+	Layouts  lookupfs.Changes
+	Pages    lookupfs.Changes
+}
+
+// Empty returns true if there are no changes
+func (r Report) Empty() bool {
+	return r.Includes.Empty() && r.Layouts.Empty() && r.Pages.Empty()
+}
+
+// Refresh looks up templates again and parses only changed ones.
+// Modification time of every file is compared with the one which was parsed before,
+// so only added and modified pages and layouts are parsed. Include change leads to parsing all of includes
+// and pages & layouts which use templates defined by changed includes (directly or via other includes).
+// Templates in use are replaced only if all of changed templates parsed successfully.
+// Refresh is safe to call while templates are rendered by other goroutines.
+func (tfs *TemplateService) Refresh() (*Report, error) {
+	tfs.mu.Lock()
+	defer tfs.mu.Unlock()
+
+	err := tfs.lfs.LookupAll()
+	if err != nil {
+		return nil, err
+	}
+	prev := tfs.snapshot()
+	files := fileSet{includes: tfs.lfs.Includes, layouts: tfs.lfs.Layouts, pages: tfs.lfs.Pages}
+	report := &Report{
+		Includes: lookupfs.Diff(prev.files.includes, files.includes),
+		Layouts:  lookupfs.Diff(prev.files.layouts, files.layouts),
+		Pages:    lookupfs.Diff(prev.files.pages, files.pages),
+	}
+	if report.Empty() {
+		return report, nil
+	}
+
+	includes := prev.includes
+	var changed map[string]bool
+	if !report.Includes.Empty() {
+		// every page and layout holds its own copy of includes, so the ones using changed includes are parsed again
+		if includes, err = tfs.parseIncludes(files.includes); err != nil {
+			return nil, err
+		}
+		changed = changedDefines(prev.includes, includes, report.Includes)
+	}
+	layouts, err := tfs.refreshTemplates(includes, prev.layouts, files.layouts, report.Layouts, changed)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := tfs.refreshTemplates(includes, prev.pages, files.pages, report.Pages, changed)
+	if err != nil {
+		return nil, err
+	}
+
+	tfs.state.Store(newSnapshot(tfs.lfs, includes, layouts, pages))
+	return report, nil
+}
+
+// refreshTemplates returns templates with removed ones deleted and added & modified ones parsed.
+// Templates which refer to any of changed include templates are parsed too
+func (tfs *TemplateService) refreshTemplates(includes includeSet, prev map[string]*templatePool,
+	items map[string]lookupfs.File, changes lookupfs.Changes, changed map[string]bool) (map[string]*templatePool, error) {
+
+	templates := make(map[string]*templatePool, len(items))
+	parse := map[string]bool{}
+	for k, tp := range prev {
+		if _, ok := items[k]; !ok {
+			continue
+		}
+		if len(changed) != 0 && (tp.deps == nil || refersAny(tp.deps, changed)) {
+			parse[k] = true
+			continue
+		}
+		templates[k] = tp
+	}
+	for _, names := range [][]string{changes.Added, changes.Modified} {
+		for _, k := range names {
+			parse[k] = true
+		}
+	}
+	for _, k := range setKeys(parse) {
+		tp, err := tfs.parseTemplate(includes, k, items[k])
+		if err != nil {
+			return nil, errors.Wrap(err, "parse template")
+		}
+		templates[k] = tp
+	}
+	return templates, nil
+}
+
+// changedDefines returns names of templates defined by changed includes (before and after change)
+// and by includes which refer to them
+func changedDefines(prev, next includeSet, changes lookupfs.Changes) map[string]bool {
+	names := map[string]bool{}
+	for _, list := range [][]string{changes.Added, changes.Modified, changes.Removed} {
+		for _, k := range list {
+			for _, d := range []*templateDeps{prev.deps[k], next.deps[k]} {
+				if d == nil {
+					continue
+				}
+				for _, name := range d.defines {
+					names[name] = true
+				}
+			}
+		}
+	}
+	for grown := true; grown; {
+		grown = false
+		for _, d := range next.deps {
+			if !refersAny(d, names) {
+				continue
+			}
+			for _, name := range d.defines {
+				if !names[name] {
+					names[name] = true
+					grown = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// refersAny returns true if template refers to any of names
+func refersAny(d *templateDeps, names map[string]bool) bool {
+	for _, ref := range d.refs {
+		if names[ref] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package apitpl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+)
+
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTemplate(t, dir, "includes/inc.html", `inc1`, mtime)
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page1 {{ template "inc" }}`, mtime)
+	writeTemplate(t, dir, "pages/other.html", `other`, mtime)
+
+	cfg := lookupfs.Config{
+		Includes:  "includes",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	report, err := tfs.Refresh()
+	require.NoError(t, err)
+	assert.True(t, report.Empty(), "nothing changed")
+
+	// Page changes
+	writeTemplate(t, dir, "pages/page.html", `page2 {{ template "inc" }}`, mtime.Add(time.Minute))
+	writeTemplate(t, dir, "pages/new.html", `new`, mtime)
+	require.NoError(t, os.Remove(filepath.Join(dir, "pages/other.html")))
+	report, err = tfs.Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, Report{Pages: lookupfs.Changes{
+		Added:    []string{"new"},
+		Removed:  []string{"other"},
+		Modified: []string{"page"},
+	}}, *report)
+	assert.Equal(t, "[page2 inc1]", renderPage(tfs, "page"))
+	assert.Equal(t, "[new]", renderPage(tfs, "new"))
+	assert.NotContains(t, tfs.snapshot().pages, "other")
+
+	// Unchanged page is not parsed again
+	newPage := tfs.snapshot().pages["new"]
+	writeTemplate(t, dir, "layouts/default.html", `({{ content }})`, mtime.Add(time.Minute))
+	report, err = tfs.Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, report.Layouts.Modified)
+	assert.True(t, report.Pages.Empty())
+	assert.Same(t, newPage, tfs.snapshot().pages["new"])
+	assert.Equal(t, "(new)", renderPage(tfs, "new"))
+
+	// Include change leads to parsing templates which use it only
+	layout := tfs.snapshot().layouts["default"]
+	writeTemplate(t, dir, "includes/inc.html", `inc2`, mtime.Add(time.Minute))
+	report, err = tfs.Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inc"}, report.Includes.Modified)
+	assert.Same(t, newPage, tfs.snapshot().pages["new"])
+	assert.Same(t, layout, tfs.snapshot().layouts["default"])
+	assert.Equal(t, "(page2 inc2)", renderPage(tfs, "page"))
+
+	// Page which uses include via other include is parsed too
+	writeTemplate(t, dir, "includes/wrap.html", `{{ define "wrap" }}wrap:{{ template "inc" }}{{ end }}`, mtime)
+	writeTemplate(t, dir, "pages/new.html", `new {{ template "wrap" }}`, mtime.Add(time.Minute))
+	_, err = tfs.Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, "(new wrap:inc2)", renderPage(tfs, "new"))
+	page := tfs.snapshot().pages["page"]
+	writeTemplate(t, dir, "includes/inc.html", `inc3`, mtime.Add(2*time.Minute))
+	_, err = tfs.Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, "(new wrap:inc3)", renderPage(tfs, "new"))
+	assert.Equal(t, "(page2 inc3)", renderPage(tfs, "page"))
+	assert.NotSame(t, page, tfs.snapshot().pages["page"])
+
+	// Added include is used by page which referred to undefined template
+	writeTemplate(t, dir, "pages/late.html", `{{ if false }}{{ template "late" }}{{ end }}late`, mtime)
+	_, err = tfs.Refresh()
+	require.NoError(t, err)
+	newPage = tfs.snapshot().pages["page"]
+	writeTemplate(t, dir, "includes/late.html", `{{ define "late" }}{{ end }}`, mtime)
+	_, err = tfs.Refresh()
+	require.NoError(t, err)
+	assert.True(t, tfs.snapshot().pages["late"].tmpl.Defined("late"))
+	assert.Same(t, newPage, tfs.snapshot().pages["page"])
+}
+
+func TestRefreshError(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page1`, mtime)
+	writeTemplate(t, dir, "pages/other.html", `other1`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	writeTemplate(t, dir, "pages/page.html", `{{ if }}`, mtime.Add(time.Minute))
+	writeTemplate(t, dir, "pages/other.html", `other2`, mtime.Add(time.Minute))
+	_, err = tfs.Refresh()
+	require.Error(t, err)
+	assert.Equal(t, "[page1]", renderPage(tfs, "page"))
+	assert.Equal(t, "[other1]", renderPage(tfs, "other"), "changes are applied all at once")
+
+	// Fixed page is parsed with all of previous changes
+	writeTemplate(t, dir, "pages/page.html", `page2`, mtime.Add(2*time.Minute))
+	report, err := tfs.Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other", "page"}, report.Pages.Modified)
+	assert.Equal(t, "[other2]", renderPage(tfs, "other"))
+}
+
+func TestRefreshConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTemplate(t, dir, "includes/inc.html", `inc`, mtime)
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page {{ template "inc" }}`, mtime)
+
+	cfg := lookupfs.Config{
+		Includes:  "includes",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	results := make(chan string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				got := renderPage(tfs, "page")
+				if !strings.HasPrefix(got, "[page inc") {
+					results <- got
+					return
+				}
+				tfs.PageNames(true)
+			}
+		}()
+	}
+
+	for i := 1; i <= 50; i++ {
+		mtime = mtime.Add(time.Second)
+		writeTemplate(t, dir, "includes/inc.html", fmt.Sprintf("inc%d", i), mtime)
+		writeTemplate(t, dir, "layouts/default.html", fmt.Sprintf("[{{ content }}%d]", i), mtime)
+		if i%2 == 0 {
+			writeTemplate(t, dir, "pages/extra.html", `extra`, mtime)
+		} else {
+			os.Remove(filepath.Join(dir, "pages/extra.html"))
+		}
+		if i%10 == 0 {
+			_, err = tfs.Parse()
+		} else {
+			_, err = tfs.Refresh()
+		}
+		require.NoError(t, err)
+	}
+	close(done)
+	wg.Wait()
+	close(results)
+	for got := range results {
+		t.Errorf("unexpected page content: %s", got)
+	}
+	assert.Equal(t, "[page inc5050]", renderPage(tfs, "page"))
+}
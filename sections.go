@@ -0,0 +1,113 @@
+package apitpl
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"strings"
+	"sync"
+	"weak"
+)
+
+// noBlockContent is a block_content func used when page content is not available.
+// Fallback is a plain string, so it is escaped by html/template
+func noBlockContent(name string, fallback ...string) string {
+	return strings.Join(fallback, "")
+}
+
+// blockContent returns block_content func factory for page content. Factory is called by layout rendering
+// with its context, so page sections are rendered with page output size limit until ctx is done.
+// Section is a template defined in page file like
+//
+//	{{ define "sidebar" }}...{{ end }}
+//
+// and layout includes it via
+//
+//	{{ block_content "sidebar" }}
+//
+// If page file does not define given section (templates of includes are not sections),
+// escaped fallback (if any) is returned.
+func (tfs *TemplateService) blockContent(tp *templatePool, page string, lim limits,
+	funcs template.FuncMap, data MetaData) sectionsFunc {
+
+	sections := map[string]bool{}
+	if tp.deps != nil {
+		for _, name := range tp.deps.defines {
+			sections[name] = name != page
+		}
+	}
+	return func(ctx context.Context) blockFunc {
+		funcs := copyFuncs(funcs, 1)
+		funcs["context"] = func() context.Context { return ctx }
+		return func(name string, fallback ...string) (interface{}, error) {
+			if !sections[name] {
+				return noBlockContent(name, fallback...), nil
+			}
+			tmpl, err := tp.get()
+			if err != nil {
+				return "", err
+			}
+			defer tp.put(tmpl)
+			buf := tfs.bufPool.Get()
+			defer tfs.bufPool.Put(buf)
+			err = tmpl.Funcs(funcs).ExecuteTemplate(contextWriter(ctx, lim.writer(buf, 0)), name, data)
+			if err == nil {
+				err = ctx.Err()
+			}
+			if err != nil {
+				return "", lim.error(ctx, name, err)
+			}
+			return template.HTML(buf.String()), nil
+		}
+	}
+}
+
+// blockFunc is a block_content func of rendered page.
+// It returns rendered section as template.HTML or fallback as string
+type blockFunc = func(string, ...string) (interface{}, error)
+
+// sectionsFunc returns block_content func of rendered page for layout context
+type sectionsFunc = func(ctx context.Context) blockFunc
+
+// sectionStore holds block_content func factories of rendered page contents until their layout is rendered.
+// Contents are weak keys, so content which is never passed to Render does not hold its page data
+type sectionStore struct {
+	mu    sync.Mutex
+	funcs map[weak.Pointer[bytes.Buffer]]sectionsFunc
+	sweep int
+}
+
+// minSweep is a store size when stale entries are removed first time
+const minSweep = 64
+
+// set stores block_content func factory of content
+func (ss *sectionStore) set(content *bytes.Buffer, fn sectionsFunc) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.funcs == nil {
+		ss.funcs = map[weak.Pointer[bytes.Buffer]]sectionsFunc{}
+	}
+	ss.funcs[weak.Make(content)] = fn
+	if len(ss.funcs) < max(ss.sweep, minSweep) {
+		return
+	}
+	for k := range ss.funcs {
+		if k.Value() == nil {
+			delete(ss.funcs, k)
+		}
+	}
+	ss.sweep = 2 * len(ss.funcs)
+}
+
+// take removes block_content func factory of content from store and returns it (nil if not found)
+func (ss *sectionStore) take(content *bytes.Buffer) sectionsFunc {
+	if content == nil {
+		return nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	k := weak.Make(content)
+	fn := ss.funcs[k]
+	delete(ss.funcs, k)
+	return fn
+}
@@ -0,0 +1,124 @@
+package apitpl
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+func TestBlockContent(t *testing.T) {
+	cfg := lookupfs.Config{
+		Includes:  "inc_minimal",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      "testdata",
+	}
+	for _, parseAlways := range []bool{false, true} {
+		tfs, err := New(64).LookupFS(lookupfs.New(cfg)).ParseAlways(parseAlways).Parse()
+		require.NoError(t, err)
+
+		page := samplemeta.NewMeta(200, "text/html")
+		page.SetLayout("sections")
+		var b bytes.Buffer
+		err = tfs.Execute(&b, "sections", template.FuncMap{}, page)
+		require.NoError(t, err)
+		assert.Equal(t, `<head><meta name="description" content="Sections"></head>
+page with sections
+<aside>no sidebar</aside>
+`, b.String())
+
+		// Nil funcs are allowed
+		page = samplemeta.NewMeta(200, "text/html")
+		page.SetLayout("sections")
+		b.Reset()
+		require.NoError(t, tfs.Execute(&b, "sections", nil, page))
+		assert.Contains(t, b.String(), "page with sections")
+
+		// Includes and page itself are not sections
+		page = samplemeta.NewMeta(200, "text/html")
+		funcs := template.FuncMap{}
+		content := tfs.RenderContent("sections", funcs, page)
+		assert.Empty(t, funcs, "caller funcs are not changed")
+		sections := tfs.sections.take(content)
+		require.NotNil(t, sections)
+		blockContent := sections(context.Background())
+		tfs.ReleaseContent(content)
+		for _, name := range []string{"inc", "sections"} {
+			s, err := blockContent(name, "fallback")
+			require.NoError(t, err)
+			assert.Equal(t, "fallback", s)
+		}
+	}
+}
+
+func TestBlockContentInclude(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now()
+	writeTemplate(t, dir, "includes/side.html", `{{ define "sidebar" }}INCLUDE SIDEBAR{{ end }}`, mtime)
+	writeTemplate(t, dir, "layouts/default.html", `<aside>{{ block_content "sidebar" .Title }}</aside>{{ content }}`, mtime)
+	writeTemplate(t, dir, "pages/plain.html", `{{ .SetTitle "<script>x</script>" }}plain`, mtime)
+	writeTemplate(t, dir, "pages/own.html", `{{ define "sidebar" }}<b>own</b>{{ end }}own`, mtime)
+
+	cfg := lookupfs.Config{
+		Includes:  "includes",
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	// Include template is not a page section, fallback is escaped
+	assert.Equal(t, "<aside>&lt;script&gt;x&lt;/script&gt;</aside>plain", renderPage(tfs, "plain"))
+	// Page section overrides include template
+	assert.Equal(t, "<aside><b>own</b></aside>own", renderPage(tfs, "own"))
+}
+
+func TestBlockContentPageError(t *testing.T) {
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      "testdata",
+	}
+	tfs, err := New(64).LookupFS(lookupfs.New(cfg)).Parse()
+	require.NoError(t, err)
+
+	page := samplemeta.NewMeta(200, "text/html")
+	page.SetLayout("sections")
+	var b bytes.Buffer
+	err = tfs.Execute(&b, "unknown", template.FuncMap{}, page)
+	require.NoError(t, err)
+	assert.Equal(t, "<head></head>\n<aside>no sidebar</aside>\n", b.String())
+}
+
+func TestSectionStore(t *testing.T) {
+	var ss sectionStore
+	blockFn := func(ctx context.Context) blockFunc { return nil }
+	kept := &bytes.Buffer{}
+	ss.set(kept, blockFn)
+	for i := 2; i < minSweep; i++ {
+		ss.set(&bytes.Buffer{}, blockFn)
+	}
+	// Lost contents are removed on store growth
+	runtime.GC()
+	ss.set(&bytes.Buffer{}, blockFn)
+	assert.Less(t, len(ss.funcs), minSweep)
+	assert.NotNil(t, ss.take(kept))
+	assert.Nil(t, ss.take(kept), "func is taken once")
+	assert.Nil(t, ss.take(nil))
+}
@@ -0,0 +1,70 @@
+package apitpl
+
+import (
+	"time"
+)
+
+// Watch enables template reloading on filesystem changes.
+// Lookup filesystem is polled every interval since Parse call and if any template was added, removed or modified,
+// changed templates are parsed again via Refresh and replace previous ones.
+// On reload error previous templates are kept and error is passed to errFunc (if given).
+func (tfs *TemplateService) Watch(interval time.Duration, errFunc func(error)) *TemplateService {
+	tfs.watchInterval = interval
+	tfs.watchErrors = errFunc
+	return tfs
+}
+
+// Stop stops filesystem watching and waits for reload in progress (if any),
+// so errFunc is not called after Stop returns. Stop must not be called from errFunc
+func (tfs *TemplateService) Stop() {
+	tfs.mu.Lock()
+	stop, done := tfs.watchStop, tfs.watchDone
+	tfs.watchStop, tfs.watchDone = nil, nil
+	tfs.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// startWatch starts filesystem polling if it was enabled and not started yet
+func (tfs *TemplateService) startWatch() {
+	tfs.mu.Lock()
+	defer tfs.mu.Unlock()
+	if tfs.watchInterval <= 0 || tfs.watchStop != nil {
+		return
+	}
+	tfs.watchStop = make(chan struct{})
+	tfs.watchDone = make(chan struct{})
+	go tfs.watch(tfs.watchStop, tfs.watchDone)
+}
+
+// watch polls filesystem until stop is closed, done is closed on return
+func (tfs *TemplateService) watch(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(tfs.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := tfs.reloadChanged(); err != nil && tfs.watchErrors != nil {
+				tfs.watchErrors(err)
+			}
+		}
+	}
+}
+
+// reloadChanged reloads templates if filesystem was changed
+func (tfs *TemplateService) reloadChanged() error {
+	tfs.mu.Lock()
+	changed, err := tfs.lfs.Changed()
+	tfs.mu.Unlock()
+	if err != nil || !changed {
+		return err
+	}
+	_, err = tfs.Refresh()
+	return err
+}
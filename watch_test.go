@@ -0,0 +1,166 @@
+package apitpl
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apisite/apitpl/lookupfs"
+	"github.com/apisite/apitpl/samplemeta"
+)
+
+// writeTemplate creates or updates template file and sets its modification time
+func writeTemplate(t *testing.T, dir, name, contents string, mtime time.Time) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+// renderPage executes page and returns result or error text
+func renderPage(tfs *TemplateService, name string) string {
+	page := samplemeta.NewMeta(200, "text/html")
+	var b bytes.Buffer
+	if err := tfs.Execute(&b, name, template.FuncMap{}, page); err != nil {
+		return err.Error()
+	}
+	return b.String()
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page1`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	// errFunc is called by watcher goroutine
+	var mu sync.Mutex
+	var errs []error
+	tfs, err := New(64).
+		LookupFS(lookupfs.New(cfg)).
+		Watch(10*time.Millisecond, func(e error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, e)
+		}).
+		Parse()
+	require.NoError(t, err)
+	defer tfs.Stop()
+	assert.Equal(t, "[page1]", renderPage(tfs, "page"))
+
+	// modified page
+	writeTemplate(t, dir, "pages/page.html", `page2`, mtime.Add(time.Minute))
+	assert.Eventually(t, func() bool { return renderPage(tfs, "page") == "[page2]" }, time.Second, 10*time.Millisecond)
+
+	// added page
+	writeTemplate(t, dir, "pages/new.html", `new`, mtime)
+	assert.Eventually(t, func() bool { return renderPage(tfs, "new") == "[new]" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"new", "page"}, tfs.PageNames(false))
+
+	// removed page
+	require.NoError(t, os.Remove(filepath.Join(dir, "pages/new.html")))
+	assert.Eventually(t, func() bool { return len(tfs.PageNames(false)) == 1 }, time.Second, 10*time.Millisecond)
+	page := samplemeta.NewMeta(200, "text/html")
+	assert.Nil(t, tfs.RenderContent("new", template.FuncMap{}, page))
+	assert.EqualError(t, page.Error(), "page new does not exist")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, errs)
+}
+
+func TestWatchParseError(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page1`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	errs := make(chan error, 1)
+	tfs, err := New(64).
+		LookupFS(lookupfs.New(cfg)).
+		Watch(10*time.Millisecond, func(e error) {
+			// error is reported on every poll until fixed, keep the first one
+			select {
+			case errs <- e:
+			default:
+			}
+		}).
+		Parse()
+	require.NoError(t, err)
+	defer tfs.Stop()
+
+	writeTemplate(t, dir, "pages/page.html", `{{ if }}`, mtime.Add(time.Minute))
+	select {
+	case e := <-errs:
+		assert.Contains(t, e.Error(), "parse template")
+	case <-time.After(time.Second):
+		t.Fatal("reload error was not reported")
+	}
+	// previous template is still in use
+	assert.Equal(t, "[page1]", renderPage(tfs, "page"))
+}
+
+func TestWatchStop(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour)
+	writeTemplate(t, dir, "layouts/default.html", `[{{ content }}]`, mtime)
+	writeTemplate(t, dir, "pages/page.html", `page1`, mtime)
+
+	cfg := lookupfs.Config{
+		Layouts:   "layouts",
+		Pages:     "pages",
+		Ext:       ".html",
+		DefLayout: "default",
+		Root:      dir,
+	}
+	var mu sync.Mutex
+	var stopped bool
+	calls := make(chan struct{}, 1)
+	tfs, err := New(64).
+		LookupFS(lookupfs.New(cfg)).
+		Watch(time.Millisecond, func(e error) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.False(t, stopped, "errFunc called after Stop")
+			select {
+			case calls <- struct{}{}:
+			default:
+			}
+		}).
+		Parse()
+	require.NoError(t, err)
+
+	writeTemplate(t, dir, "pages/page.html", `{{ if }}`, mtime.Add(time.Minute))
+	select {
+	case <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("reload error was not reported")
+	}
+	tfs.Stop()
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	// second call is noop
+	tfs.Stop()
+}